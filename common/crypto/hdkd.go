@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"math/big"
+
+	schnorrkel "github.com/ChainSafe/go-schnorrkel"
+	"github.com/tsfdsong/go-polkadot/common/u8compact"
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+// Ed25519HardDerive derives the seed of a hard junction, the blake2-256 of the
+// SCALE encoded ("Ed25519HDKD", seed, chain code)
+func Ed25519HardDerive(seed []byte, chainCode [32]byte) [32]byte {
+	return hardDerive("Ed25519HDKD", seed, chainCode)
+}
+
+// Secp256k1HardDerive derives the secret of a hard junction, the blake2-256 of
+// the SCALE encoded ("Secp256k1HDKD", secret, chain code)
+func Secp256k1HardDerive(secret []byte, chainCode [32]byte) [32]byte {
+	return hardDerive("Secp256k1HDKD", secret, chainCode)
+}
+
+// Sr25519HardDerive derives the key pair of a hard junction, the mini secret
+// key derived from the secret being expanded as NewSr25519KeyPairFromSeed does
+func Sr25519HardDerive(secret [64]byte, chainCode [32]byte) ([32]byte, [64]byte, error) {
+	mini, _, err := sr25519SecretKey(secret).HardDeriveMiniSecretKey([]byte{}, chainCode)
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	seed := mini.Encode()
+	return NewSr25519KeyPairFromSeed(seed[:])
+}
+
+// Sr25519SoftDerive derives the key pair of a soft junction. The public key of
+// a soft junction can also be derived from the parent public key alone.
+func Sr25519SoftDerive(secret [64]byte, chainCode [32]byte) ([32]byte, [64]byte, error) {
+	extended, err := schnorrkel.DeriveKeySimple(sr25519SecretKey(secret), []byte{}, chainCode)
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	derived, err := extended.Secret()
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	// NOTE: the library draws a random nonce, which it keeps private. The nonce
+	// only seeds the signatures, derive it from the parent so the derivation
+	// stays deterministic.
+	key := derived.Encode()
+	nonce := NewBlake2b256(u8util.Concat(secret[32:], key[:]))
+
+	var derivedSecret [64]byte
+	copy(derivedSecret[:32], key[:])
+	copy(derivedSecret[32:], nonce[:])
+
+	pub, err := NewSr25519PublicKey(derivedSecret)
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	return pub, derivedSecret, nil
+}
+
+// Sr25519SoftDerivePublic derives the public key of a soft junction from the
+// parent public key
+func Sr25519SoftDerivePublic(publicKey [32]byte, chainCode [32]byte) ([32]byte, error) {
+	pub, err := schnorrkel.NewPublicKey(publicKey)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	extended, err := schnorrkel.DeriveKeySimple(pub, []byte{}, chainCode)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	derived, err := extended.Public()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return derived.Encode(), nil
+}
+
+// hardDerive ...
+func hardDerive(tag string, seed []byte, chainCode [32]byte) [32]byte {
+	encoded := u8util.Concat(
+		u8compact.CompactToUint8Slice(big.NewInt(int64(len(tag))), u8compact.DefaultBitLength),
+		[]byte(tag),
+		seed,
+		chainCode[:],
+	)
+
+	return *NewBlake2b256(encoded)
+}
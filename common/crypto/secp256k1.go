@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// NOTE: signatures are r, s and the recovery id (0 or 1), as Substrate encodes
+// them. The compact format of the library starts with 27 plus the recovery id.
+var compactSigMagicOffset byte = 27
+
+// ErrInvalidSecp256k1Secret is returned for a secret key of zero or not below
+// the order of the curve
+var ErrInvalidSecp256k1Secret = errors.New("invalid secp256k1 secret key")
+
+// NewSecp256k1KeyPairFromSeed uses the 32 byte seed as the secret key, as
+// Substrate does, returning the 33 byte compressed public key. Seeds of zero
+// or not below the order of the curve are rejected, not reduced.
+func NewSecp256k1KeyPairFromSeed(seed []byte) ([]byte, [32]byte, error) {
+	if len(seed) != 32 {
+		return nil, [32]byte{}, errors.New("secp256k1 seed must be 32 bytes")
+	}
+
+	var secret [32]byte
+	copy(secret[:], seed)
+
+	pub, err := NewSecp256k1PublicKey(secret)
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+
+	return pub, secret, nil
+}
+
+// NewSecp256k1PublicKey returns the compressed public key of a secret
+func NewSecp256k1PublicKey(secret [32]byte) ([]byte, error) {
+	key, err := secp256k1PrivKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.PubKey().SerializeCompressed(), nil
+}
+
+// Secp256k1Sign signs the blake2-256 hash of message, returning a 65 byte
+// recoverable signature
+func Secp256k1Sign(secret [32]byte, message []byte) ([]byte, error) {
+	if message == nil || len(message) == 0 {
+		return nil, errors.New("cannot sign nil message")
+	}
+
+	key, err := secp256k1PrivKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := NewBlake2b256(message)
+	compact := ecdsa.SignCompact(key, hash[:], false)
+
+	sig := make([]byte, 65)
+	copy(sig, compact[1:])
+	sig[64] = compact[0] - compactSigMagicOffset
+
+	return sig, nil
+}
+
+// Secp256k1Recover returns the compressed public key that signed the blake2-256
+// hash of message
+func Secp256k1Recover(message []byte, signature []byte) ([]byte, error) {
+	if len(signature) != 65 {
+		return nil, errors.New("secp256k1 signature must be 65 bytes")
+	}
+
+	recoveryID := signature[64]
+	if recoveryID >= compactSigMagicOffset {
+		// NOTE: accept the Ethereum style recovery ids as well
+		recoveryID -= compactSigMagicOffset
+	}
+	if recoveryID > 3 {
+		return nil, errors.New("invalid secp256k1 recovery id")
+	}
+
+	compact := make([]byte, 65)
+	compact[0] = recoveryID + compactSigMagicOffset
+	copy(compact[1:], signature[:64])
+
+	hash := NewBlake2b256(message)
+	pub, _, err := ecdsa.RecoverCompact(compact, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return pub.SerializeCompressed(), nil
+}
+
+// Secp256k1Verify returns true if signature is a valid signature of the
+// blake2-256 hash of message by the compressed public key
+func Secp256k1Verify(message []byte, signature []byte, publicKey []byte) bool {
+	recovered, err := Secp256k1Recover(message, signature)
+	if err != nil {
+		return false
+	}
+
+	return string(recovered) == string(publicKey)
+}
+
+// Secp256k1AccountID returns the Substrate account ID of a compressed public
+// key, its blake2-256 hash
+func Secp256k1AccountID(publicKey []byte) [32]byte {
+	return *NewBlake2b256(publicKey)
+}
+
+// EthereumAddress returns the 20 byte Ethereum address of a public key, the
+// last 20 bytes of the keccak-256 of the uncompressed key without its prefix
+func EthereumAddress(publicKey []byte) ([20]byte, error) {
+	pub, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return [20]byte{}, err
+	}
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(pub.SerializeUncompressed()[1:])
+
+	var addr [20]byte
+	copy(addr[:], hash.Sum(nil)[12:])
+	return addr, nil
+}
+
+// secp256k1PrivKey returns the key of secret, which must be in [1, n)
+func secp256k1PrivKey(secret [32]byte) (*secp256k1.PrivateKey, error) {
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(secret[:]); overflow || scalar.IsZero() {
+		return nil, ErrInvalidSecp256k1Secret
+	}
+
+	return secp256k1.NewPrivateKey(&scalar), nil
+}
@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+func TestNewSecp256k1KeyPairFromSeed(t *testing.T) {
+	// NOTE: test vector of Substrate's ecdsa Pair::from_seed
+	pub, _, err := NewSecp256k1KeyPairFromSeed(u8util.FromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "028db55b05db86c0b1786ca49f095d76344c9e6056b2f02701a7e7f3c20aabfd91"
+	if hex.EncodeToString(pub) != expected {
+		t.Errorf("want %v; got %v", expected, hex.EncodeToString(pub))
+	}
+
+	t.Run("rejects an invalid secret", func(t *testing.T) {
+		if _, _, err := NewSecp256k1KeyPairFromSeed(make([]byte, 32)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	// NOTE: the order of the curve, and the largest seed
+	for i, seed := range []string{
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+	} {
+		t.Run(fmt.Sprintf("rejects the out of range seed %v", i), func(t *testing.T) {
+			if _, _, err := NewSecp256k1KeyPairFromSeed(u8util.FromHex(seed)); err != ErrInvalidSecp256k1Secret {
+				t.Errorf("want %v; got %v", ErrInvalidSecp256k1Secret, err)
+			}
+		})
+	}
+
+	t.Run("accepts the largest valid seed", func(t *testing.T) {
+		if _, _, err := NewSecp256k1KeyPairFromSeed(u8util.FromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140")); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestSecp256k1SignVerify(t *testing.T) {
+	pub, secret, err := NewSecp256k1KeyPairFromSeed(u8util.FromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("this is a message")
+	sig, err := Secp256k1Sign(secret, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ends with the recovery id", func(t *testing.T) {
+		if len(sig) != 65 || sig[64] > 1 {
+			t.Errorf("unexpected signature %x", sig)
+		}
+	})
+
+	t.Run("verifies the signature", func(t *testing.T) {
+		if !Secp256k1Verify(message, sig, pub) {
+			t.Error("expected the signature to verify")
+		}
+		if Secp256k1Verify([]byte("another message"), sig, pub) {
+			t.Error("expected the signature not to verify")
+		}
+	})
+
+	t.Run("recovers the public key", func(t *testing.T) {
+		recovered, err := Secp256k1Recover(message, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(recovered) != hex.EncodeToString(pub) {
+			t.Errorf("want %x; got %x", pub, recovered)
+		}
+	})
+}
+
+func TestEthereumAddress(t *testing.T) {
+	secret := [32]byte{31: 1}
+	pub, err := NewSecp256k1PublicKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, err := EthereumAddress(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "7e5f4552091a69125d5dfcb7b8c2659029395bdf"
+	if hex.EncodeToString(addr[:]) != expected {
+		t.Errorf("want %v; got %v", expected, hex.EncodeToString(addr[:]))
+	}
+}
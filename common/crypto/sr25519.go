@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"crypto/sha512"
+	"errors"
+
+	schnorrkel "github.com/ChainSafe/go-schnorrkel"
+)
+
+// Sr25519SigningContext is the signing context of Substrate, signatures made
+// with another context don't verify on chain
+var Sr25519SigningContext = []byte("substrate")
+
+// NewSr25519KeyPairFromSeed derives the key pair of a 32 byte mini secret key
+// the way Substrate does, expanding it in ed25519 mode. The secret is the
+// schnorrkel secret key followed by the signing nonce.
+func NewSr25519KeyPairFromSeed(seed []byte) ([32]byte, [64]byte, error) {
+	if len(seed) != schnorrkel.MiniSecretKeySize {
+		return [32]byte{}, [64]byte{}, errors.New("sr25519 seed must be 32 bytes")
+	}
+
+	var mini [schnorrkel.MiniSecretKeySize]byte
+	copy(mini[:], seed)
+
+	miniSecret, err := schnorrkel.NewMiniSecretKeyFromRaw(mini)
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	// NOTE: the library keeps the nonce private, it is the upper half of the
+	// hash the ed25519 expansion starts from
+	hash := sha512.Sum512(seed)
+	key := miniSecret.ExpandEd25519().Encode()
+
+	var secret [64]byte
+	copy(secret[:32], key[:])
+	copy(secret[32:], hash[32:])
+
+	pub, err := NewSr25519PublicKey(secret)
+	if err != nil {
+		return [32]byte{}, [64]byte{}, err
+	}
+
+	return pub, secret, nil
+}
+
+// NewSr25519PublicKey returns the public key of a secret
+func NewSr25519PublicKey(secret [64]byte) ([32]byte, error) {
+	pub, err := sr25519SecretKey(secret).Public()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return pub.Encode(), nil
+}
+
+// Sr25519Sign signs message in the Substrate signing context
+func Sr25519Sign(secret [64]byte, message []byte) ([]byte, error) {
+	if message == nil || len(message) == 0 {
+		return nil, errors.New("cannot sign nil message")
+	}
+
+	transcript := schnorrkel.NewSigningContext(Sr25519SigningContext, message)
+	sig, err := sr25519SecretKey(secret).Sign(transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := sig.Encode()
+	return encoded[:], nil
+}
+
+// Sr25519Verify returns true if signature is a valid signature of message by
+// public key, in the Substrate signing context
+func Sr25519Verify(message []byte, signature []byte, publicKey [32]byte) bool {
+	if len(signature) != schnorrkel.SignatureSize {
+		return false
+	}
+
+	pub, err := schnorrkel.NewPublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+
+	var encoded [schnorrkel.SignatureSize]byte
+	copy(encoded[:], signature)
+
+	sig := new(schnorrkel.Signature)
+	if err := sig.Decode(encoded); err != nil {
+		return false
+	}
+
+	ok, err := pub.Verify(sig, schnorrkel.NewSigningContext(Sr25519SigningContext, message))
+	return err == nil && ok
+}
+
+// Sr25519ToEd25519Bytes returns the secret in the form Substrate and
+// polkadot.js export it, the key multiplied by the cofactor followed by the
+// nonce
+func Sr25519ToEd25519Bytes(secret [64]byte) [64]byte {
+	var high byte
+	for i := 0; i < 32; i++ {
+		r := secret[i] >> 5
+		secret[i] = secret[i]<<3 | high
+		high = r
+	}
+
+	return secret
+}
+
+// Sr25519FromEd25519Bytes is the inverse of Sr25519ToEd25519Bytes
+func Sr25519FromEd25519Bytes(secret [64]byte) [64]byte {
+	var low byte
+	for i := 31; i >= 0; i-- {
+		r := secret[i] & 0x07
+		secret[i] = secret[i]>>3 | low
+		low = r << 5
+	}
+
+	return secret
+}
+
+// sr25519SecretKey ...
+func sr25519SecretKey(secret [64]byte) *schnorrkel.SecretKey {
+	var key, nonce [32]byte
+	copy(key[:], secret[:32])
+	copy(nonce[:], secret[32:])
+
+	return schnorrkel.NewSecretKey(key, nonce)
+}
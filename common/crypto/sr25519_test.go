@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+func TestNewSr25519KeyPairFromSeed(t *testing.T) {
+	// NOTE: test vectors of Substrate's sr25519 Pair::from_seed
+	for i, tt := range []struct {
+		seed string
+		pub  string
+	}{
+		{"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60", "44a996beb1eef7bdcab976ab6d2ca26104834164ecf28fb375600576fcc6eb0f"},
+		{"fac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e", "46ebddef8cd9bb167dc30878d7113b7e168e6f0646beffd77d69d39bad76b47a"},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			pub, secret, err := NewSr25519KeyPairFromSeed(u8util.FromHex(tt.seed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(pub[:]) != tt.pub {
+				t.Errorf("want %v; got %v", tt.pub, hex.EncodeToString(pub[:]))
+			}
+
+			derived, err := NewSr25519PublicKey(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if derived != pub {
+				t.Errorf("expected the public key of the secret to match")
+			}
+		})
+	}
+
+	t.Run("rejects short seeds", func(t *testing.T) {
+		if _, _, err := NewSr25519KeyPairFromSeed([]byte("short")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestSr25519Ed25519Bytes(t *testing.T) {
+	seed := u8util.FromHex("fac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e")
+	_, secret, err := NewSr25519KeyPairFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NOTE: the ed25519 form is the clamped hash of the seed
+	hash := sha512.Sum512(seed)
+	hash[0] &= 248
+	hash[31] &= 63
+	hash[31] |= 64
+
+	exported := Sr25519ToEd25519Bytes(secret)
+	if !bytes.Equal(exported[:], hash[:]) {
+		t.Errorf("want %x; got %x", hash, exported)
+	}
+
+	if Sr25519FromEd25519Bytes(exported) != secret {
+		t.Error("expected the secret back")
+	}
+}
+
+func TestSr25519SignVerify(t *testing.T) {
+	pub, secret, err := NewSr25519KeyPairFromSeed(u8util.FromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("this is a message")
+	sig, err := Sr25519Sign(secret, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("marks the signature as schnorrkel", func(t *testing.T) {
+		if len(sig) != 64 || sig[63]&128 == 0 {
+			t.Errorf("unexpected signature %x", sig)
+		}
+	})
+
+	t.Run("verifies the signature", func(t *testing.T) {
+		if !Sr25519Verify(message, sig, pub) {
+			t.Error("expected the signature to verify")
+		}
+	})
+
+	t.Run("rejects another message", func(t *testing.T) {
+		if Sr25519Verify([]byte("another message"), sig, pub) {
+			t.Error("expected the signature not to verify")
+		}
+	})
+
+	t.Run("rejects another key", func(t *testing.T) {
+		other, _, err := NewSr25519KeyPairFromSeed(make([]byte, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Sr25519Verify(message, sig, other) {
+			t.Error("expected the signature not to verify")
+		}
+	})
+}
+
+func TestSr25519VerifyKnown(t *testing.T) {
+	// NOTE: a signature made in the "substrate" context by the development
+	// root key, from the test vectors of sr25519-crust
+	pub := [32]byte{}
+	copy(pub[:], u8util.FromHex("46ebddef8cd9bb167dc30878d7113b7e168e6f0646beffd77d69d39bad76b47a"))
+	message := []byte("this is a message")
+	sig := u8util.FromHex("4e172314444b8f820bb54c22e95076f220ed25373e5c178234aa6c211d29271244b947e3ff3418ff6b45fd1df1140c8cbff69fc58ee6dc96df70936a2bb74b82")
+
+	t.Run("verifies the signature", func(t *testing.T) {
+		if !Sr25519Verify(message, sig, pub) {
+			t.Error("expected the signature to verify")
+		}
+	})
+
+	t.Run("rejects another message", func(t *testing.T) {
+		if Sr25519Verify([]byte("this is another message"), sig, pub) {
+			t.Error("expected the signature not to verify")
+		}
+	})
+}
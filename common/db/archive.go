@@ -0,0 +1,226 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The archive starts with a header of magic (4), version (1) and record count
+// (8), followed by the records, each a key length (4), key, value length (4),
+// value and the crc32 of these fields (4). A trailer of the record count (8) and
+// the crc32 of all records (4) closes it. Integers are big endian.
+var archiveMagic = []byte("pdbx")
+var archiveVersion byte = 1
+var archiveBatchSize = 1024
+var archiveMaxLength uint32 = 1 << 30
+
+// Export writes the contents of src to w. The database must implement Iterable.
+func Export(src BaseDB, w io.Writer, fn *ProgressCB) error {
+	iterable, ok := src.(Iterable)
+	if !ok {
+		return errors.New("export requires an iterable database")
+	}
+
+	count := 0
+	iterable.Iterate(nil, func(key, value []uint8) bool {
+		count++
+		return true
+	})
+
+	buf := bufio.NewWriter(w)
+	header := make([]byte, len(archiveMagic)+1+8)
+	copy(header, archiveMagic)
+	header[len(archiveMagic)] = archiveVersion
+	binary.BigEndian.PutUint64(header[len(archiveMagic)+1:], uint64(count))
+	if _, err := buf.Write(header); err != nil {
+		return err
+	}
+
+	checksum := crc32.NewIEEE()
+	records := io.MultiWriter(buf, checksum)
+	written := 0
+
+	var err error
+	iterable.Iterate(nil, func(key, value []uint8) bool {
+		// NOTE: entries added after counting are left for the next export
+		if written == count {
+			return false
+		}
+		if err = writeRecord(records, key, value); err != nil {
+			return false
+		}
+
+		written++
+		reportArchiveProgress(fn, written, count)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if written != count {
+		return fmt.Errorf("expected %d entries, exported %d", count, written)
+	}
+
+	trailer := make([]byte, 8+4)
+	binary.BigEndian.PutUint64(trailer, uint64(written))
+	binary.BigEndian.PutUint32(trailer[8:], checksum.Sum32())
+	if _, err := buf.Write(trailer); err != nil {
+		return err
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	completeArchiveProgress(fn, written)
+	return nil
+}
+
+// Import reads an archive written by Export from r into dst. The entries are
+// held in a single batch, written once the whole archive is verified, so a
+// corrupt archive leaves dst untouched.
+func Import(dst BaseDB, r io.Reader, fn *ProgressCB) error {
+	buf := bufio.NewReader(r)
+
+	header := make([]byte, len(archiveMagic)+1+8)
+	if _, err := io.ReadFull(buf, header); err != nil {
+		return fmt.Errorf("failed to read archive header: %v", err)
+	}
+	if !bytes.Equal(header[:len(archiveMagic)], archiveMagic) {
+		return errors.New("not an archive")
+	}
+	if version := header[len(archiveMagic)]; version != archiveVersion {
+		return fmt.Errorf("unsupported archive version %d", version)
+	}
+	count := int(binary.BigEndian.Uint64(header[len(archiveMagic)+1:]))
+
+	checksum := crc32.NewIEEE()
+	records := io.TeeReader(buf, checksum)
+	batch := newBatch(dst)
+
+	for read := 1; read <= count; read++ {
+		key, value, err := readRecord(records)
+		if err != nil {
+			return fmt.Errorf("failed to read entry %d: %v", read, err)
+		}
+
+		batch.Put(key, value)
+		reportArchiveProgress(fn, read, count)
+	}
+
+	trailer := make([]byte, 8+4)
+	if _, err := io.ReadFull(buf, trailer); err != nil {
+		return fmt.Errorf("failed to read archive trailer: %v", err)
+	}
+	if int(binary.BigEndian.Uint64(trailer)) != count {
+		return errors.New("archive trailer count mismatch")
+	}
+	if binary.BigEndian.Uint32(trailer[8:]) != checksum.Sum32() {
+		return errors.New("archive checksum mismatch")
+	}
+
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	completeArchiveProgress(fn, count)
+	return nil
+}
+
+// writeRecord ...
+func writeRecord(w io.Writer, key, value []uint8) error {
+	record := make([]byte, 0, 4+len(key)+4+len(value)+4)
+	record = appendUint32(record, uint32(len(key)))
+	record = append(record, key...)
+	record = appendUint32(record, uint32(len(value)))
+	record = append(record, value...)
+	record = appendUint32(record, crc32.ChecksumIEEE(record))
+
+	_, err := w.Write(record)
+	return err
+}
+
+// readRecord ...
+func readRecord(r io.Reader) ([]uint8, []uint8, error) {
+	checksum := crc32.NewIEEE()
+	tee := io.TeeReader(r, checksum)
+
+	key, err := readField(tee)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := readField(tee)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expected := make([]byte, 4)
+	if _, err := io.ReadFull(r, expected); err != nil {
+		return nil, nil, err
+	}
+	if binary.BigEndian.Uint32(expected) != checksum.Sum32() {
+		return nil, nil, errors.New("checksum mismatch")
+	}
+
+	return key, value, nil
+}
+
+// readField reads a length-prefixed field
+func readField(r io.Reader) ([]uint8, error) {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(length)
+	if size > archiveMaxLength {
+		return nil, fmt.Errorf("field length %d exceeds the maximum", size)
+	}
+
+	field := make([]uint8, size)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// appendUint32 ...
+func appendUint32(b []byte, value uint32) []byte {
+	encoded := make([]byte, 4)
+	binary.BigEndian.PutUint32(encoded, value)
+	return append(b, encoded...)
+}
+
+// reportArchiveProgress reports every archiveBatchSize entries
+func reportArchiveProgress(fn *ProgressCB, done, count int) {
+	if fn == nil || done%archiveBatchSize != 0 {
+		return
+	}
+
+	f := *fn
+	f(&ProgressValue{
+		IsCompleted: false,
+		Keys:        done,
+		Percent:     float64(done) * 100 / float64(count),
+	})
+}
+
+// completeArchiveProgress ...
+func completeArchiveProgress(fn *ProgressCB, count int) {
+	if fn == nil {
+		return
+	}
+
+	f := *fn
+	f(&ProgressValue{
+		IsCompleted: true,
+		Keys:        count,
+		Percent:     100,
+	})
+}
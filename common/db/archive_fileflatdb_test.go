@@ -0,0 +1,55 @@
+package db_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/fileflatdb"
+)
+
+// NOTE: an external test, fileflatdb imports db
+func TestArchiveFileFlatDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := fileflatdb.NewFileFlatDB(dir, "store.db", nil)
+	src.Open()
+	defer src.Close()
+
+	keys := []string{"a", "a\x00", "b", "block/header"}
+	for _, key := range keys {
+		src.Put([]uint8(key), []uint8("value "+key))
+	}
+
+	var archive bytes.Buffer
+	if err := db.Export(src, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := db.NewMemoryDB(nil)
+	if err := db.Import(dst, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	dst.Iterate(nil, func(key, value []uint8) bool {
+		found = append(found, string(key))
+		return true
+	})
+	if !reflect.DeepEqual(found, keys) {
+		t.Errorf("expected the keys %q, got %q", keys, found)
+	}
+
+	for _, key := range keys {
+		if !reflect.DeepEqual(dst.Get([]uint8(key)), []uint8("value "+key)) {
+			t.Errorf("value mismatch for %q", key)
+		}
+	}
+}
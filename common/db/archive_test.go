@@ -0,0 +1,83 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	src := NewMemoryDB(nil)
+	for _, key := range []string{"a", "b", "c"} {
+		src.Put([]uint8(key), []uint8("value "+key))
+	}
+
+	var archive bytes.Buffer
+	if err := Export(src, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("imports an exported database", func(t *testing.T) {
+		dst := NewMemoryDB(nil)
+
+		var completed bool
+		var progress ProgressCB = func(value *ProgressValue) {
+			completed = value.IsCompleted && value.Keys == 3
+		}
+
+		if err := Import(dst, bytes.NewReader(archive.Bytes()), &progress); err != nil {
+			t.Fatal(err)
+		}
+		if !completed {
+			t.Error("expected completed progress")
+		}
+		if !reflect.DeepEqual(dst.storage, src.storage) {
+			t.Errorf("expected %v, got %v", src.storage, dst.storage)
+		}
+	})
+
+	t.Run("rejects a corrupt archive", func(t *testing.T) {
+		corrupt := append([]byte(nil), archive.Bytes()...)
+		corrupt[len(archiveMagic)+1+8+4] ^= 0xff
+
+		dst := NewMemoryDB(nil)
+		if err := Import(dst, bytes.NewReader(corrupt), nil); err == nil {
+			t.Error("expected a checksum error")
+		}
+		if len(dst.storage) != 0 {
+			t.Errorf("expected nothing imported, got %v", dst.storage)
+		}
+	})
+
+	t.Run("imports nothing of an archive corrupt at the end", func(t *testing.T) {
+		large := NewMemoryDB(nil)
+		for i := 0; i <= archiveBatchSize; i++ {
+			large.Put([]uint8(fmt.Sprintf("%08d", i)), []uint8("value"))
+		}
+
+		var buf bytes.Buffer
+		if err := Export(large, &buf, nil); err != nil {
+			t.Fatal(err)
+		}
+		// NOTE: the last byte of the last value, before its crc32 and the trailer
+		corrupt := buf.Bytes()
+		corrupt[len(corrupt)-12-4-1] ^= 0xff
+
+		dst := NewMemoryDB(nil)
+		if err := Import(dst, bytes.NewReader(corrupt), nil); err == nil {
+			t.Error("expected a checksum error")
+		}
+		if len(dst.storage) != 0 {
+			t.Errorf("expected nothing imported, got %d entries", len(dst.storage))
+		}
+	})
+
+	t.Run("rejects a truncated archive", func(t *testing.T) {
+		truncated := archive.Bytes()[:archive.Len()-1]
+
+		if err := Import(NewMemoryDB(nil), bytes.NewReader(truncated), nil); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
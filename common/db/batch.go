@@ -0,0 +1,41 @@
+package db
+
+// sequentialBatch applies the writes one at a time, for databases that don't
+// implement Batcher. A failure halfway leaves the earlier writes applied.
+type sequentialBatch struct {
+	db  BaseDB
+	ops []*KV
+}
+
+// newBatch returns an atomic batch when db supports it, a sequential one otherwise
+func newBatch(db BaseDB) Batch {
+	if batcher, ok := db.(Batcher); ok {
+		return batcher.NewBatch()
+	}
+
+	return &sequentialBatch{db: db}
+}
+
+// Put ...
+func (s *sequentialBatch) Put(key, value []uint8) {
+	s.ops = append(s.ops, &KV{Key: key, Value: value})
+}
+
+// Del ...
+func (s *sequentialBatch) Del(key []uint8) {
+	s.ops = append(s.ops, &KV{Key: key, Value: nil})
+}
+
+// Write ...
+func (s *sequentialBatch) Write() error {
+	for _, kv := range s.ops {
+		if kv.Value == nil {
+			s.db.Del(kv.Key)
+		} else {
+			s.db.Put(kv.Key, kv.Value)
+		}
+	}
+
+	s.ops = nil
+	return nil
+}
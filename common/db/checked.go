@@ -0,0 +1,31 @@
+package db
+
+// tryDel deletes through the checked variant when db implements Checked
+func tryDel(db BaseDB, key []uint8) error {
+	if checked, ok := db.(Checked); ok {
+		return checked.TryDel(key)
+	}
+
+	db.Del(key)
+	return nil
+}
+
+// tryPut writes through the checked variant when db implements Checked
+func tryPut(db BaseDB, key, value []uint8) error {
+	if checked, ok := db.(Checked); ok {
+		return checked.TryPut(key, value)
+	}
+
+	db.Put(key, value)
+	return nil
+}
+
+// tryEmpty empties through the checked variant when db implements Checked
+func tryEmpty(db BaseDB) error {
+	if checked, ok := db.(Checked); ok {
+		return checked.TryEmpty()
+	}
+
+	db.Empty()
+	return nil
+}
@@ -1,5 +1,16 @@
 package db
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrReadOnly is returned when writing to a database opened read-only
+var ErrReadOnly = errors.New("database is read-only")
+
+// ErrNotForkable is returned when forking a database that isn't Forkable
+var ErrNotForkable = errors.New("database does not support forking")
+
 // BaseOptions ...
 type BaseOptions struct {
 }
@@ -8,15 +19,52 @@ type BaseOptions struct {
 type ProgressValue struct {
 	IsCompleted bool
 	Keys        int
-	Percent     int
+	Bytes       int
+	Percent     float64
 }
 
 // ProgressCB ...
 type ProgressCB func(*ProgressValue)
 
+// SyncPolicy controls when written data is flushed to stable storage
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing to the operating system
+	SyncNever SyncPolicy = iota
+	// SyncEveryCommit flushes after every completed write
+	SyncEveryCommit
+	// SyncPeriodic flushes at most once per SyncInterval
+	SyncPeriodic
+)
+
+// Compression selects the codec used for stored values
+type Compression int
+
+const (
+	// CompressionNone stores values as-is
+	CompressionNone Compression = iota
+	// CompressionSnappy favours speed, suited to hot stores
+	CompressionSnappy
+	// CompressionZstd favours ratio, suited to cold archival stores
+	CompressionZstd
+)
+
 // BaseDBOptions ....
+// NOTE: IsCompressed is kept for existing callers and selects snappy
 type BaseDBOptions struct {
 	IsCompressed bool
+	Compression  Compression
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	UseMmap      bool
+	// LruSize is the number of values kept by LruDB, 0 for the default
+	LruSize int
+	// ReadOnly opens an existing database without write access
+	ReadOnly bool
+	// ErrorIfMissing refuses to open a database that doesn't exist, by
+	// default a missing database is created
+	ErrorIfMissing bool
 }
 
 // BaseDB ...
@@ -34,6 +82,41 @@ type BaseDB interface {
 	Put(key, value []uint8)
 }
 
+// Checked is implemented by databases that can refuse writes, such as those
+// opened read-only. Use these on such databases, the BaseDB writes can only
+// log the error and drop the write.
+type Checked interface {
+	TryDel(key []uint8) error
+	TryPut(key, value []uint8) error
+	TryEmpty() error
+}
+
+// Iterable is implemented by databases that can list their contents. Iterate
+// calls fn for every key starting with prefix, in ascending key order, until
+// fn returns false.
+type Iterable interface {
+	Iterate(prefix []uint8, fn func(key, value []uint8) bool)
+}
+
+// Batch collects writes that are applied together by Write, either all of
+// them or none
+type Batch interface {
+	Put(key, value []uint8)
+	Del(key []uint8)
+	Write() error
+}
+
+// Batcher is implemented by databases that can apply a Batch atomically
+type Batcher interface {
+	NewBatch() Batch
+}
+
+// Forkable is implemented by databases that can fork a copy-on-write copy of
+// their contents, writes to the copy and to the original not being shared
+type Forkable interface {
+	Fork() (BaseDB, error)
+}
+
 // TXDB ...
 type TXDB interface {
 	BaseDB
@@ -1,30 +1,68 @@
 package db
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/tsfdsong/go-polkadot/common/dirutil"
 )
 
+var journalFile = ".journal"
+var tempSuffix = ".tmp"
+var defaultShardDepth = 1
+
+// NOTE: the file of a key with no hex left once the directories are taken
+var emptyFile = "_"
+var chunkRe = regexp.MustCompile(`.{1,6}`)
+
+// FileTreeDBOptions ...
+type FileTreeDBOptions struct {
+	// ShardDepth is the number of directory levels keys are spread over, each
+	// named after 3 bytes of the key. 0 selects the default. A database must
+	// always be opened with the depth it was created with.
+	ShardDepth int
+}
+
 // FilePath ...
 type FilePath struct {
 	Directory string
 	File      string
 }
 
-// FileTreeDB ...
+// FileTreeDB stores every value in its own file, named after the hex encoded key
 type FileTreeDB struct {
-	location string
+	location   string
+	shardDepth int
+	mu         sync.Mutex
+	// NOTE: the total size of the values, computed on the first call to Size
+	size      int64
+	sizeKnown bool
 }
 
 // NewFileTreeDBDB ...
 func NewFileTreeDBDB(location string) *FileTreeDB {
+	return NewFileTreeDB(location, nil)
+}
+
+// NewFileTreeDB ...
+func NewFileTreeDB(location string, options *FileTreeDBOptions) *FileTreeDB {
+	shardDepth := defaultShardDepth
+	if options != nil && options.ShardDepth > 0 {
+		shardDepth = options.ShardDepth
+	}
+
 	return &FileTreeDB{
-		location: location,
+		location:   location,
+		shardDepth: shardDepth,
 	}
 }
 
@@ -33,24 +71,43 @@ func (f *FileTreeDB) Close() {
 	// NOTE: noop
 }
 
-// Open ...
+// Open replays a batch that was interrupted after being committed
 func (f *FileTreeDB) Open() {
-	// NOTE: noop
+	if err := f.replayJournal(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-// Drop ...
+// Drop removes the database directory
 func (f *FileTreeDB) Drop() {
-	log.Println("drop is not implemented")
+	if err := os.RemoveAll(f.location); err != nil {
+		log.Fatal(err)
+	}
+
+	f.setSize(0)
 }
 
-// Empty ...
+// Empty removes all values, keeping the database directory
 func (f *FileTreeDB) Empty() {
-	log.Println("empty is not implemented")
+	f.Drop()
+
+	if err := os.MkdirAll(f.location, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
 }
 
-// Rename ...
+// Rename moves the database directory to base/file
 func (f *FileTreeDB) Rename(base, file string) {
-	log.Println("rename is not implemented")
+	location := dirutil.NormalizePath(fmt.Sprintf("%s/%s", base, file))
+
+	if err := os.MkdirAll(base, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(f.location, location); err != nil {
+		log.Fatal(err)
+	}
+
+	f.location = location
 }
 
 // Maintain ...
@@ -66,21 +123,36 @@ func (f *FileTreeDB) Maintain(fn *ProgressCB) error {
 	return nil
 }
 
-// Size ...
+// Size returns the total size of the values. The directory is walked once,
+// after which the size is kept up to date by the writes.
 func (f *FileTreeDB) Size() int {
-	log.Println("size is not implemented")
-	return 0
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.sizeKnown {
+		var size int64
+		f.iterateDirectory(f.location, "", "", func(key, value []uint8) bool {
+			size += int64(len(value))
+			return true
+		})
+
+		f.size = size
+		f.sizeKnown = true
+	}
+
+	return int(f.size)
 }
 
 // Del ...
 func (f *FileTreeDB) Del(key []uint8) {
 	filepath := f.getFilePath(key)
+	previous := fileSize(filepath.File)
 
-	if _, err := os.Stat(filepath.File); !os.IsNotExist(err) {
-		if os.Remove(filepath.File); err != nil {
-			log.Fatal(err)
-		}
+	if err := os.Remove(filepath.File); err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
 	}
+
+	f.addSize(-previous)
 }
 
 // Get ...
@@ -103,32 +175,285 @@ func (f *FileTreeDB) Get(key []uint8) []uint8 {
 func (f *FileTreeDB) Put(key, value []uint8) {
 	filepath := f.getFilePath(key)
 
-	if _, err := os.Stat(filepath.Directory); os.IsNotExist(err) {
-		if err := os.MkdirAll(filepath.Directory, os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Directory, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+
+	previous := fileSize(filepath.File)
+
+	// NOTE: written aside and renamed in place, so readers never see a partial value
+	temp, err := ioutil.TempFile(filepath.Directory, ".put-*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := temp.Write(value); err != nil {
+		log.Fatal(err)
+	}
+	if err := temp.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(temp.Name(), filepath.File); err != nil {
+		log.Fatal(err)
+	}
+
+	f.addSize(int64(len(value)) - previous)
+}
+
+// Iterate ...
+func (f *FileTreeDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	f.iterateDirectory(f.location, "", hex.EncodeToString(prefix), fn)
+}
+
+// iterateDirectory walks directory in name order, the hex encoded key being the
+// concatenation of the directory names and the file name. Returns false once fn
+// stopped the iteration.
+func (f *FileTreeDB) iterateDirectory(directory, path, prefix string, fn func(key, value []uint8) bool) bool {
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true
+		}
+
+		log.Fatal(err)
+	}
+
+	// NOTE: the empty file ends the key, it sorts before the longer keys
+	for i, entry := range entries {
+		if entry.Name() == emptyFile {
+			copy(entries[1:i+1], entries[:i])
+			entries[0] = entry
+			break
+		}
+	}
+
+	for _, entry := range entries {
+		name := path + entry.Name()
+		if entry.Name() == emptyFile && !entry.IsDir() {
+			name = path
+		}
+
+		// NOTE: only descend where the prefix can still match
+		if !strings.HasPrefix(name, prefix) && !(entry.IsDir() && strings.HasPrefix(prefix, name)) {
+			continue
+		}
+
+		location := fmt.Sprintf("%s/%s", directory, entry.Name())
+		if entry.IsDir() {
+			if !f.iterateDirectory(location, name, prefix, fn) {
+				return false
+			}
+			continue
+		}
+
+		key, err := hex.DecodeString(name)
+		if err != nil {
+			continue
+		}
+
+		value, err := ioutil.ReadFile(location)
+		if err != nil {
 			log.Fatal(err)
 		}
+
+		if !fn(key, value) {
+			return false
+		}
 	}
 
-	if err := ioutil.WriteFile(filepath.File, value, 0644); err != nil {
-		log.Fatal(err)
+	return true
+}
+
+// NewBatch returns a batch written through temp files. The renames that put
+// the values in place are journaled first, so Open completes an interrupted
+// batch.
+func (f *FileTreeDB) NewBatch() Batch {
+	return &fileTreeBatch{db: f}
+}
+
+// journalEntry ...
+type journalEntry struct {
+	Key    []uint8
+	Delete bool
+}
+
+// fileTreeBatch ...
+type fileTreeBatch struct {
+	db  *FileTreeDB
+	ops []*KV
+}
+
+// Put ...
+func (b *fileTreeBatch) Put(key, value []uint8) {
+	b.ops = append(b.ops, &KV{Key: key, Value: value})
+}
+
+// Del ...
+func (b *fileTreeBatch) Del(key []uint8) {
+	b.ops = append(b.ops, &KV{Key: key, Value: nil})
+}
+
+// Write ...
+func (b *fileTreeBatch) Write() error {
+	// NOTE: only the last write to a key is kept, the temp file holds one value
+	latest := map[string]*KV{}
+	var order []string
+	for _, kv := range b.ops {
+		if _, found := latest[string(kv.Key)]; !found {
+			order = append(order, string(kv.Key))
+		}
+		latest[string(kv.Key)] = kv
+	}
+
+	entries := make([]*journalEntry, 0, len(order))
+	for _, key := range order {
+		kv := latest[key]
+		entries = append(entries, &journalEntry{Key: kv.Key, Delete: kv.Value == nil})
+		if kv.Value == nil {
+			continue
+		}
+
+		if err := writeFileSync(b.db.getFilePath(kv.Key).File+tempSuffix, kv.Value); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+		return err
+	}
+
+	// NOTE: the rename commits the batch
+	journal := b.db.journalPath()
+	if err := writeFileSync(journal+tempSuffix, buf.Bytes()); err != nil {
+		return err
+	}
+	if err := os.Rename(journal+tempSuffix, journal); err != nil {
+		return err
+	}
+
+	b.ops = nil
+	return b.db.replayJournal()
+}
+
+// replayJournal moves the values of a committed batch in place
+func (f *FileTreeDB) replayJournal() error {
+	journal := f.journalPath()
+	os.Remove(journal + tempSuffix)
+
+	data, err := ioutil.ReadFile(journal)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries []*journalEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return fmt.Errorf("corrupt batch journal %s: %v", journal, err)
 	}
+
+	for _, entry := range entries {
+		filepath := f.getFilePath(entry.Key)
+
+		var err error
+		if entry.Delete {
+			err = os.Remove(filepath.File)
+		} else {
+			err = os.Rename(filepath.File+tempSuffix, filepath.File)
+		}
+
+		// NOTE: entries applied before an interruption are already in place
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// NOTE: the replaced values are not known, Size walks the directory again
+	f.mu.Lock()
+	f.sizeKnown = false
+	f.mu.Unlock()
+
+	return os.Remove(journal)
+}
+
+// journalPath ...
+func (f *FileTreeDB) journalPath() string {
+	return fmt.Sprintf("%s/%s", f.location, journalFile)
+}
+
+// writeFileSync writes data to file, flushing it to stable storage
+func writeFileSync(file string, data []byte) error {
+	if err := os.MkdirAll(path.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+
+	handle, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := handle.Write(data); err != nil {
+		handle.Close()
+		return err
+	}
+	if err := handle.Sync(); err != nil {
+		handle.Close()
+		return err
+	}
+
+	return handle.Close()
 }
 
 // getFilePath ...
 func (f *FileTreeDB) getFilePath(key []uint8) *FilePath {
-	dirDepth := 1
-
 	// NOTE: We want to limit the number of entries in any specific directory. Split the
 	// key into parts and use this to construct the path and the actual filename. We want
 	// to limit the entries per directory, but at the same time minimize the number of
 	// directories we need to create (when non-existent as well as the size overhead)
-	re := regexp.MustCompile(`.{1,6}`)
-	parts := re.FindAllString(hex.EncodeToString(key), -1)
-	directory := fmt.Sprintf("%s/%s", f.location, strings.Join(parts[0:dirDepth], "/"))
-	file := fmt.Sprintf("%s/%s", directory, strings.Join(parts[dirDepth:], ""))
+	parts := chunkRe.FindAllString(hex.EncodeToString(key), -1)
+
+	dirDepth := f.shardDepth
+	file := emptyFile
+	if len(parts) > dirDepth {
+		file = strings.Join(parts[dirDepth:], "")
+	} else {
+		dirDepth = len(parts)
+	}
+
+	directory := strings.Join(append([]string{f.location}, parts[0:dirDepth]...), "/")
 
 	return &FilePath{
 		Directory: directory,
-		File:      file,
+		File:      fmt.Sprintf("%s/%s", directory, file),
 	}
 }
+
+// addSize adjusts the known size by delta
+func (f *FileTreeDB) addSize(delta int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.size += delta
+}
+
+// setSize ...
+func (f *FileTreeDB) setSize(size int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.size = size
+	f.sizeKnown = true
+}
+
+// fileSize returns the size of file, 0 when it doesn't exist
+func fileSize(file string) int64 {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return 0
+	}
+
+	return stat.Size()
+}
@@ -1 +1,239 @@
 package db
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileTreeDBIterate(t *testing.T) {
+	filetreeDb := NewFileTreeDBDB(t.TempDir())
+
+	keys := [][]uint8{
+		{0x01, 0x02, 0x03, 0x04},
+		{0x01, 0x02, 0x03, 0x05},
+		{0x01, 0x02, 0x04, 0x01},
+		{0x02, 0x00, 0x00, 0x00},
+	}
+	for i, key := range keys {
+		filetreeDb.Put(key, []uint8{uint8(i)})
+	}
+
+	t.Run("iterates in key order", func(t *testing.T) {
+		var found [][]uint8
+		filetreeDb.Iterate(nil, func(key, value []uint8) bool {
+			if !reflect.DeepEqual(value, []uint8{uint8(len(found))}) {
+				t.Errorf("value mismatch for %x", key)
+			}
+			found = append(found, key)
+			return true
+		})
+
+		if !reflect.DeepEqual(found, keys) {
+			t.Errorf("unexpected keys %x", found)
+		}
+	})
+
+	t.Run("iterates a prefix", func(t *testing.T) {
+		var found [][]uint8
+		filetreeDb.Iterate([]uint8{0x01, 0x02, 0x03}, func(key, value []uint8) bool {
+			found = append(found, key)
+			return true
+		})
+
+		if !reflect.DeepEqual(found, keys[:2]) {
+			t.Errorf("unexpected keys %x", found)
+		}
+	})
+}
+
+func TestFileTreeDBBatch(t *testing.T) {
+	location := t.TempDir()
+	filetreeDb := NewFileTreeDBDB(location)
+	filetreeDb.Open()
+
+	deleted := []uint8{0x01, 0x02, 0x03, 0x04}
+	written := []uint8{0x05, 0x06, 0x07, 0x08}
+	filetreeDb.Put(deleted, []uint8("deleted"))
+
+	t.Run("writes the batch", func(t *testing.T) {
+		batch := filetreeDb.NewBatch()
+		batch.Put(written, []uint8("first"))
+		batch.Del(deleted)
+		batch.Put(written, []uint8("written"))
+
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if filetreeDb.Get(deleted) != nil {
+			t.Error("expected the key to be deleted")
+		}
+		if !reflect.DeepEqual(filetreeDb.Get(written), []uint8("written")) {
+			t.Error("expected the last value to be written")
+		}
+	})
+
+	t.Run("completes an interrupted batch on open", func(t *testing.T) {
+		filetreeDb.Put(deleted, []uint8("deleted"))
+
+		entries := []*journalEntry{{Key: written, Delete: false}, {Key: deleted, Delete: true}}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+			t.Fatal(err)
+		}
+		// NOTE: a committed batch, interrupted before any of it was applied
+		if err := writeFileSync(filetreeDb.journalPath(), buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileSync(filetreeDb.getFilePath(written).File+tempSuffix, []uint8("replayed")); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := NewFileTreeDBDB(location)
+		reopened.Open()
+
+		if reopened.Get(deleted) != nil {
+			t.Error("expected the key to be deleted")
+		}
+		if !reflect.DeepEqual(reopened.Get(written), []uint8("replayed")) {
+			t.Error("expected the journaled value to be moved in place")
+		}
+		if _, err := os.Stat(reopened.journalPath()); !os.IsNotExist(err) {
+			t.Error("expected the journal to be removed")
+		}
+	})
+}
+
+func TestFileTreeDBShardDepth(t *testing.T) {
+	location := t.TempDir()
+	filetreeDb := NewFileTreeDB(location, &FileTreeDBOptions{ShardDepth: 2})
+
+	keys := [][]uint8{
+		{0x01, 0x02},
+		{0x01, 0x02, 0x03, 0x04},
+		{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	}
+	for i, key := range keys {
+		filetreeDb.Put(key, []uint8{uint8(i)})
+	}
+
+	t.Run("spreads keys over the directory levels", func(t *testing.T) {
+		files := []string{
+			"/0102/_",
+			"/010203/04/_",
+			"/010203/040506/07",
+		}
+		for _, file := range files {
+			if _, err := os.Stat(location + file); err != nil {
+				t.Errorf("expected %s to exist: %v", file, err)
+			}
+		}
+	})
+
+	t.Run("reads the keys back", func(t *testing.T) {
+		for i, key := range keys {
+			if !reflect.DeepEqual(filetreeDb.Get(key), []uint8{uint8(i)}) {
+				t.Errorf("value mismatch for %x", key)
+			}
+		}
+	})
+
+	t.Run("iterates in key order", func(t *testing.T) {
+		var found [][]uint8
+		filetreeDb.Iterate(nil, func(key, value []uint8) bool {
+			found = append(found, key)
+			return true
+		})
+
+		if !reflect.DeepEqual(found, keys) {
+			t.Errorf("unexpected keys %x", found)
+		}
+	})
+}
+
+func TestFileTreeDBSize(t *testing.T) {
+	location := t.TempDir()
+	filetreeDb := NewFileTreeDBDB(location)
+	filetreeDb.Put([]uint8{0x01}, []uint8("one"))
+	filetreeDb.Put([]uint8{0x02}, []uint8("two"))
+
+	t.Run("walks the directory", func(t *testing.T) {
+		if size := filetreeDb.Size(); size != 6 {
+			t.Errorf("expected size 6, got %d", size)
+		}
+	})
+
+	t.Run("follows the writes", func(t *testing.T) {
+		filetreeDb.Put([]uint8{0x01}, []uint8("three"))
+		filetreeDb.Del([]uint8{0x02})
+		filetreeDb.Del([]uint8{0x03})
+
+		if size := filetreeDb.Size(); size != 5 {
+			t.Errorf("expected size 5, got %d", size)
+		}
+	})
+
+	t.Run("follows the batches", func(t *testing.T) {
+		batch := filetreeDb.NewBatch()
+		batch.Put([]uint8{0x04}, []uint8("four"))
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+
+		if size := filetreeDb.Size(); size != 9 {
+			t.Errorf("expected size 9, got %d", size)
+		}
+	})
+
+	t.Run("leaves no temp files", func(t *testing.T) {
+		entries, err := ioutil.ReadDir(location + "/01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("expected a single file, got %d", len(entries))
+		}
+	})
+}
+
+func TestFileTreeDBDropRename(t *testing.T) {
+	base := t.TempDir()
+	filetreeDb := NewFileTreeDBDB(base + "/from")
+	filetreeDb.Put([]uint8{0x01}, []uint8("one"))
+
+	t.Run("renames the directory", func(t *testing.T) {
+		filetreeDb.Rename(base+"/moved", "to")
+
+		if _, err := os.Stat(base + "/from"); !os.IsNotExist(err) {
+			t.Errorf("expected the old directory to be gone")
+		}
+		if !reflect.DeepEqual(filetreeDb.Get([]uint8{0x01}), []uint8("one")) {
+			t.Errorf("expected the value at the new location")
+		}
+	})
+
+	t.Run("empties the directory", func(t *testing.T) {
+		filetreeDb.Empty()
+
+		if filetreeDb.Get([]uint8{0x01}) != nil {
+			t.Errorf("expected the value to be removed")
+		}
+		if _, err := os.Stat(base + "/moved/to"); err != nil {
+			t.Errorf("expected the directory to remain: %v", err)
+		}
+	})
+
+	t.Run("drops the directory", func(t *testing.T) {
+		filetreeDb.Drop()
+
+		if _, err := os.Stat(base + "/moved/to"); !os.IsNotExist(err) {
+			t.Errorf("expected the directory to be removed")
+		}
+		if size := filetreeDb.Size(); size != 0 {
+			t.Errorf("expected size 0, got %d", size)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"github.com/tsfdsong/go-polkadot/common/metrics"
+)
+
+// InstrumentedDB wraps a BaseDB, counting and timing the reads and writes
+// passing through. Metrics are registered as <name>_<metric>.
+type InstrumentedDB struct {
+	backing      BaseDB
+	gets         *metrics.Counter
+	hits         *metrics.Counter
+	puts         *metrics.Counter
+	dels         *metrics.Counter
+	bytesRead    *metrics.Counter
+	bytesWritten *metrics.Counter
+	getLatency   *metrics.Histogram
+	putLatency   *metrics.Histogram
+	delLatency   *metrics.Histogram
+	batchLatency *metrics.Histogram
+}
+
+// NewInstrumentedDB ...
+func NewInstrumentedDB(backing BaseDB, registry *metrics.Registry, name string) *InstrumentedDB {
+	return &InstrumentedDB{
+		backing:      backing,
+		gets:         registry.Counter(name + "_gets"),
+		hits:         registry.Counter(name + "_get_hits"),
+		puts:         registry.Counter(name + "_puts"),
+		dels:         registry.Counter(name + "_dels"),
+		bytesRead:    registry.Counter(name + "_bytes_read"),
+		bytesWritten: registry.Counter(name + "_bytes_written"),
+		getLatency:   registry.Histogram(name + "_get_seconds"),
+		putLatency:   registry.Histogram(name + "_put_seconds"),
+		delLatency:   registry.Histogram(name + "_del_seconds"),
+		batchLatency: registry.Histogram(name + "_batch_seconds"),
+	}
+}
+
+// Close ...
+func (i *InstrumentedDB) Close() {
+	i.backing.Close()
+}
+
+// Open ...
+func (i *InstrumentedDB) Open() {
+	i.backing.Open()
+}
+
+// Drop ...
+func (i *InstrumentedDB) Drop() {
+	i.backing.Drop()
+}
+
+// Empty ...
+func (i *InstrumentedDB) Empty() {
+	i.backing.Empty()
+}
+
+// Rename ...
+func (i *InstrumentedDB) Rename(base, file string) {
+	i.backing.Rename(base, file)
+}
+
+// Maintain ...
+func (i *InstrumentedDB) Maintain(fn *ProgressCB) error {
+	return i.backing.Maintain(fn)
+}
+
+// Size ...
+func (i *InstrumentedDB) Size() int {
+	return i.backing.Size()
+}
+
+// Del ...
+func (i *InstrumentedDB) Del(key []uint8) {
+	defer i.delLatency.ObserveSince(time.Now())
+
+	i.backing.Del(key)
+	i.dels.Inc()
+}
+
+// Get ...
+func (i *InstrumentedDB) Get(key []uint8) []uint8 {
+	defer i.getLatency.ObserveSince(time.Now())
+
+	value := i.backing.Get(key)
+	i.gets.Inc()
+	if value != nil {
+		i.hits.Inc()
+		i.bytesRead.Add(int64(len(value)))
+	}
+
+	return value
+}
+
+// Put ...
+func (i *InstrumentedDB) Put(key, value []uint8) {
+	defer i.putLatency.ObserveSince(time.Now())
+
+	i.backing.Put(key, value)
+	i.puts.Inc()
+	i.bytesWritten.Add(int64(len(key) + len(value)))
+}
+
+// Iterate ...
+func (i *InstrumentedDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	iterable, ok := i.backing.(Iterable)
+	if !ok {
+		log.Println("iterate is not supported")
+		return
+	}
+
+	iterable.Iterate(prefix, fn)
+}
+
+// NewBatch returns a batch of the backing whose writes are counted once it
+// is written
+func (i *InstrumentedDB) NewBatch() Batch {
+	return &instrumentedBatch{
+		db:    i,
+		batch: newBatch(i.backing),
+	}
+}
+
+// instrumentedBatch ...
+type instrumentedBatch struct {
+	db    *InstrumentedDB
+	batch Batch
+	puts  int64
+	dels  int64
+	bytes int64
+}
+
+// Put ...
+func (b *instrumentedBatch) Put(key, value []uint8) {
+	b.batch.Put(key, value)
+	b.puts++
+	b.bytes += int64(len(key) + len(value))
+}
+
+// Del ...
+func (b *instrumentedBatch) Del(key []uint8) {
+	b.batch.Del(key)
+	b.dels++
+}
+
+// Write ...
+func (b *instrumentedBatch) Write() error {
+	defer b.db.batchLatency.ObserveSince(time.Now())
+
+	if err := b.batch.Write(); err != nil {
+		return err
+	}
+
+	b.db.puts.Add(b.puts)
+	b.db.dels.Add(b.dels)
+	b.db.bytesWritten.Add(b.bytes)
+	b.puts, b.dels, b.bytes = 0, 0, 0
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/metrics"
+)
+
+func TestInstrumentedDB(t *testing.T) {
+	registry := metrics.NewRegistry()
+	lrudb := NewLruDB(NewMemoryDB(nil), 1)
+	lrudb.SetMetrics(registry, "lru")
+	instrumented := NewInstrumentedDB(lrudb, registry, "state")
+
+	instrumented.Put([]uint8("a"), []uint8("value"))
+	instrumented.Put([]uint8("b"), []uint8("value"))
+	instrumented.Get([]uint8("b"))
+	instrumented.Get([]uint8("a"))
+	instrumented.Get([]uint8("c"))
+
+	for name, expected := range map[string]int64{
+		"state_puts":          2,
+		"state_gets":          3,
+		"state_get_hits":      2,
+		"state_bytes_read":    10,
+		"state_bytes_written": 12,
+		"lru_hits":            1,
+		"lru_misses":          2,
+		"lru_evictions":       3,
+	} {
+		if value := registry.Counter(name).Value(); value != expected {
+			t.Errorf("expected %s to be %d, got %d", name, expected, value)
+		}
+	}
+
+	if registry.Histogram("state_get_seconds").Snapshot().Count != 3 {
+		t.Error("expected the gets to be timed")
+	}
+}
+
+func TestInstrumentedDBBatch(t *testing.T) {
+	registry := metrics.NewRegistry()
+	instrumented := NewInstrumentedDB(NewMemoryDB(nil), registry, "state")
+	base := BaseDB(instrumented)
+	txdb := NewTransactionDB(&base)
+
+	ok, err := txdb.Transaction(func() bool {
+		txdb.Put([]uint8("a"), []uint8("value"))
+		txdb.Put([]uint8("b"), []uint8("value"))
+		txdb.Del([]uint8("c"))
+		return true
+	})
+	if err != nil || !ok {
+		t.Fatalf("expected the transaction to commit, %v", err)
+	}
+
+	for name, expected := range map[string]int64{
+		"state_puts":          2,
+		"state_dels":          1,
+		"state_bytes_written": 12,
+	} {
+		if value := registry.Counter(name).Value(); value != expected {
+			t.Errorf("expected %s to be %d, got %d", name, expected, value)
+		}
+	}
+
+	if registry.Histogram("state_batch_seconds").Snapshot().Count != 1 {
+		t.Error("expected the batch write to be timed")
+	}
+}
@@ -1,44 +1,66 @@
 package db
 
+import (
+	"container/list"
+	"log"
+
+	"github.com/tsfdsong/go-polkadot/common/metrics"
+)
+
 // CachedValue ...
 type CachedValue struct {
-	Value []uint8
+	Value   []uint8
+	element *list.Element
 }
 
 // LruMap ...
 type LruMap map[string]*CachedValue
 
-// LruDB ...
+// LruDB caches up to itemCount values of its backing, evicting the least
+// recently used one when full
 type LruDB struct {
-	backing BaseDB
-	lru     LruMap
+	backing   BaseDB
+	lru       LruMap
+	order     *list.List
+	itemCount int
+	hits      *metrics.Counter
+	misses    *metrics.Counter
+	evictions *metrics.Counter
 }
 
 var defaultItemCount = 4096
 
 // NewLruDB ...
-// TODO: itemCount not used?
+// itemCount <= 0 selects the default size
 func NewLruDB(backing BaseDB, itemCount int) *LruDB {
-	// TODO: this is an ineffassign...
-	//if itemCount == -1 {
-	//itemCount = defaultItemCount
-	//}
+	if itemCount <= 0 {
+		itemCount = defaultItemCount
+	}
 
 	return &LruDB{
-		backing: backing,
-		lru:     LruMap{},
+		backing:   backing,
+		lru:       LruMap{},
+		order:     list.New(),
+		itemCount: itemCount,
 	}
 }
 
+// SetMetrics registers the cache hits, misses and evictions as <name>_<metric>
+func (l *LruDB) SetMetrics(registry *metrics.Registry, name string) {
+	l.hits = registry.Counter(name + "_hits")
+	l.misses = registry.Counter(name + "_misses")
+	l.evictions = registry.Counter(name + "_evictions")
+}
+
 // Close ...
 func (l *LruDB) Close() {
-	l.lru = LruMap{}
+	l.reset()
 	l.backing.Close()
 }
 
 // Open ...
 func (l *LruDB) Open() {
-	l.lru = LruMap{}
+	l.reset()
 	l.backing.Open()
 }
 
@@ -49,8 +71,10 @@ func (l *LruDB) Drop() {
 
 // Empty ...
 func (l *LruDB) Empty() {
-	l.lru = LruMap{}
-	l.backing.Empty()
+	// NOTE: a refused write must not be cached, see Checked
+	if err := l.TryEmpty(); err != nil {
+		log.Printf("[db/lrudb] empty: %v", err)
+	}
 }
 
 // Rename ...
@@ -70,10 +94,9 @@ func (l *LruDB) Size() int {
 
 // Del ...
 func (l *LruDB) Del(key []uint8) {
-	keyStr := string(key)
-
-	l.backing.Del(key)
-	l.lru[keyStr] = &CachedValue{Value: nil}
+	if err := l.TryDel(key); err != nil {
+		log.Printf("[db/lrudb] del: %v", err)
+	}
 }
 
 // Get ...
@@ -81,18 +104,142 @@ func (l *LruDB) Get(key []uint8) []uint8 {
 	keyStr := string(key)
 	cached, found := l.lru[keyStr]
 	if found {
+		l.hits.Inc()
+		l.order.MoveToFront(cached.element)
 		return cached.Value
 	}
 
+	l.misses.Inc()
 	value := l.backing.Get(key)
-	l.lru[keyStr] = &CachedValue{Value: value}
+	l.cache(keyStr, value)
 	return value
 }
 
 // Put ...
 func (l *LruDB) Put(key, value []uint8) {
-	keyStr := string(key)
+	if err := l.TryPut(key, value); err != nil {
+		log.Printf("[db/lrudb] put: %v", err)
+	}
+}
+
+// TryDel ...
+func (l *LruDB) TryDel(key []uint8) error {
+	if err := tryDel(l.backing, key); err != nil {
+		return err
+	}
+
+	l.cache(string(key), nil)
+	return nil
+}
+
+// TryPut ...
+func (l *LruDB) TryPut(key, value []uint8) error {
+	if err := tryPut(l.backing, key, value); err != nil {
+		return err
+	}
+
+	l.cache(string(key), value)
+	return nil
+}
+
+// TryEmpty ...
+func (l *LruDB) TryEmpty() error {
+	if err := tryEmpty(l.backing); err != nil {
+		return err
+	}
+
+	l.reset()
+	return nil
+}
+
+// cache stores the value as the most recently used, evicting the least
+// recently used values beyond itemCount
+func (l *LruDB) cache(key string, value []uint8) {
+	if cached, found := l.lru[key]; found {
+		cached.Value = value
+		l.order.MoveToFront(cached.element)
+		return
+	}
+
+	l.lru[key] = &CachedValue{
+		Value:   value,
+		element: l.order.PushFront(key),
+	}
+
+	for l.order.Len() > l.itemCount {
+		l.uncache(l.order.Back().Value.(string))
+		l.evictions.Inc()
+	}
+}
+
+// uncache ...
+func (l *LruDB) uncache(key string) {
+	if cached, found := l.lru[key]; found {
+		l.order.Remove(cached.element)
+		delete(l.lru, key)
+	}
+}
+
+// reset ...
+func (l *LruDB) reset() {
+	l.lru = LruMap{}
+	l.order = list.New()
+}
+
+// Iterate ...
+func (l *LruDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	iterable, ok := l.backing.(Iterable)
+	if !ok {
+		log.Println("iterate is not supported")
+		return
+	}
+
+	iterable.Iterate(prefix, fn)
+}
+
+// NewBatch returns a batch on the backing, atomic when the backing implements
+// Batcher. The cache is updated once the batch has been written.
+func (l *LruDB) NewBatch() Batch {
+	return &lruBatch{
+		db:    l,
+		batch: newBatch(l.backing),
+	}
+}
+
+// lruBatch ...
+type lruBatch struct {
+	db    *LruDB
+	batch Batch
+	ops   []*KV
+}
+
+// Put ...
+func (b *lruBatch) Put(key, value []uint8) {
+	b.batch.Put(key, value)
+	b.ops = append(b.ops, &KV{Key: key, Value: value})
+}
+
+// Del ...
+func (b *lruBatch) Del(key []uint8) {
+	b.batch.Del(key)
+	b.ops = append(b.ops, &KV{Key: key, Value: nil})
+}
+
+// Write ...
+func (b *lruBatch) Write() error {
+	if err := b.batch.Write(); err != nil {
+		// NOTE: the backing may be partially written, drop what we know
+		for _, kv := range b.ops {
+			b.db.uncache(string(kv.Key))
+		}
+		b.ops = nil
+		return err
+	}
+
+	for _, kv := range b.ops {
+		b.db.cache(string(kv.Key), kv.Value)
+	}
 
-	l.backing.Put(key, value)
-	l.lru[keyStr] = &CachedValue{Value: value}
+	b.ops = nil
+	return nil
 }
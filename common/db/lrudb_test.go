@@ -64,4 +64,43 @@ func TestLruDB(t *testing.T) {
 			t.Fail()
 		}
 	})
+
+	t.Run("updates the cache once a batch is written", func(t *testing.T) {
+		key := []uint8("test2")
+		value := []uint8("batched")
+		lrudb.Get(key)
+
+		batch := lrudb.NewBatch()
+		batch.Put(key, value)
+		if lrudb.Get(key) != nil {
+			t.Fail()
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lrudb.Get(key), value) {
+			t.Fail()
+		}
+		if !reflect.DeepEqual(memoryDB.Get(key), value) {
+			t.Fail()
+		}
+	})
+
+	t.Run("evicts the least recently used item when full", func(t *testing.T) {
+		small := NewLruDB(baseDB, 2)
+		small.Put([]uint8("a"), []uint8("a"))
+		small.Put([]uint8("b"), []uint8("b"))
+		small.Get([]uint8("a"))
+		small.Put([]uint8("c"), []uint8("c"))
+
+		if len(small.lru) != 2 {
+			t.Errorf("expected 2 cached items, got %d", len(small.lru))
+		}
+		if _, found := small.lru["b"]; found {
+			t.Error("expected the least recently used item to be evicted")
+		}
+		if _, found := small.lru["a"]; !found {
+			t.Error("expected the recently used item to be kept")
+		}
+	})
 }
@@ -4,14 +4,31 @@ import (
 	"bytes"
 	"encoding/gob"
 	"log"
+	"sort"
+	"sync"
 )
 
 // Storage ...
 type Storage map[string][]uint8
 
-// MemoryDB ...
+// maxLayerDepth bounds the number of frozen layers a read walks through, a
+// deeper chain is flattened by the next Snapshot
+var maxLayerDepth = 16
+
+// memoryLayer is a frozen set of writes shared by the snapshots taken on top
+// of it. A nil value marks a key deleted in the layer.
+type memoryLayer struct {
+	storage Storage
+	parent  *memoryLayer
+	depth   int
+}
+
+// MemoryDB keeps its writes in storage, on top of the frozen layers of the
+// snapshots it was forked from
 type MemoryDB struct {
 	storage Storage
+	base    *memoryLayer
+	mu      sync.RWMutex
 }
 
 // NewMemoryDB creates a MemoryDB database extending TransactionDB
@@ -26,6 +43,46 @@ func NewMemoryDB(options *BaseOptions) *MemoryDB {
 	}
 }
 
+// Snapshot returns a copy-on-write copy of the database in constant time. The
+// current contents are frozen into a layer shared by both sides, the writes
+// that follow on either side are not seen by the other.
+func (m *MemoryDB) Snapshot() *MemoryDB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.storage) > 0 || m.base == nil {
+		depth := 1
+		if m.base != nil {
+			depth = m.base.depth + 1
+		}
+
+		m.base = &memoryLayer{
+			storage: m.storage,
+			parent:  m.base,
+			depth:   depth,
+		}
+		m.storage = Storage{}
+	}
+
+	// NOTE: flattening copies the contents once, amortized over the snapshots
+	if m.base.depth > maxLayerDepth {
+		m.base = &memoryLayer{
+			storage: m.merged(nil),
+			depth:   1,
+		}
+	}
+
+	return &MemoryDB{
+		storage: Storage{},
+		base:    m.base,
+	}
+}
+
+// Fork ...
+func (m *MemoryDB) Fork() (BaseDB, error) {
+	return m.Snapshot(), nil
+}
+
 // Close ...
 func (m *MemoryDB) Close() {
 	m.Empty()
@@ -43,7 +100,11 @@ func (m *MemoryDB) Drop() {
 
 // Empty ...
 func (m *MemoryDB) Empty() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.storage = Storage{}
+	m.base = nil
 }
 
 // Rename ...
@@ -54,10 +115,14 @@ func (m *MemoryDB) Rename(base, file string) {
 // Maintain ...
 func (m *MemoryDB) Maintain(fn *ProgressCB) error {
 	if fn != nil {
+		m.mu.RLock()
+		keys := len(m.merged(nil))
+		m.mu.RUnlock()
+
 		f := *fn
 		f(&ProgressValue{
 			IsCompleted: true,
-			Keys:        len(m.storage),
+			Keys:        keys,
 			Percent:     100,
 		})
 	}
@@ -66,10 +131,13 @@ func (m *MemoryDB) Maintain(fn *ProgressCB) error {
 
 // Size ...
 func (m *MemoryDB) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
 
-	if err := enc.Encode(m.storage); err != nil {
+	if err := enc.Encode(m.merged(nil)); err != nil {
 		log.Fatal(err)
 	}
 
@@ -78,20 +146,133 @@ func (m *MemoryDB) Size() int {
 
 // Del ...
 func (m *MemoryDB) Del(key []uint8) {
-	delete(m.storage, string(key))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.del(key)
 }
 
 // Get ...
 func (m *MemoryDB) Get(key []uint8) []uint8 {
-	value, found := m.storage[string(key)]
-	if found {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if value, found := m.storage[string(key)]; found {
 		return value
 	}
 
+	for layer := m.base; layer != nil; layer = layer.parent {
+		if value, found := layer.storage[string(key)]; found {
+			return value
+		}
+	}
+
 	return nil
 }
 
 // Put ...
 func (m *MemoryDB) Put(key []uint8, value []uint8) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.storage[string(key)] = value
 }
+
+// Iterate ...
+// NOTE: iterates a snapshot taken under the lock, so fn may write to the database
+func (m *MemoryDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	m.mu.RLock()
+	values := m.merged(prefix)
+	m.mu.RUnlock()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !fn([]uint8(key), values[key]) {
+			return
+		}
+	}
+}
+
+// del removes key, leaving a tombstone when a frozen layer may hold it. The
+// caller holds the lock.
+func (m *MemoryDB) del(key []uint8) {
+	if m.base == nil {
+		delete(m.storage, string(key))
+		return
+	}
+
+	m.storage[string(key)] = nil
+}
+
+// merged returns the live entries starting with prefix, resolving the layers
+// from the oldest to the newest. The caller holds the lock.
+func (m *MemoryDB) merged(prefix []uint8) Storage {
+	var layers []Storage
+	for layer := m.base; layer != nil; layer = layer.parent {
+		layers = append(layers, layer.storage)
+	}
+
+	values := Storage{}
+	apply := func(storage Storage) {
+		for key, value := range storage {
+			if !bytes.HasPrefix([]byte(key), prefix) {
+				continue
+			}
+			if value == nil {
+				delete(values, key)
+			} else {
+				values[key] = value
+			}
+		}
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		apply(layers[i])
+	}
+	apply(m.storage)
+
+	return values
+}
+
+// NewBatch ...
+func (m *MemoryDB) NewBatch() Batch {
+	return &memoryBatch{db: m}
+}
+
+// memoryBatch applies its writes under the database lock
+type memoryBatch struct {
+	db  *MemoryDB
+	ops []*KV
+}
+
+// Put ...
+func (b *memoryBatch) Put(key, value []uint8) {
+	b.ops = append(b.ops, &KV{Key: key, Value: value})
+}
+
+// Del ...
+func (b *memoryBatch) Del(key []uint8) {
+	b.ops = append(b.ops, &KV{Key: key, Value: nil})
+}
+
+// Write ...
+func (b *memoryBatch) Write() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	for _, kv := range b.ops {
+		if kv.Value == nil {
+			b.db.del(kv.Key)
+		} else {
+			b.db.storage[string(kv.Key)] = kv.Value
+		}
+	}
+
+	b.ops = nil
+	return nil
+}
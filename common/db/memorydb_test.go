@@ -54,3 +54,128 @@ func TestMemoryDB(t *testing.T) {
 	// Close the memory database
 	memoryDb.Close()
 }
+
+func TestMemoryDBIterate(t *testing.T) {
+	memoryDb := NewMemoryDB(nil)
+	memoryDb.Open()
+	defer memoryDb.Close()
+
+	for _, key := range []string{"b2", "a1", "b1", "c1"} {
+		memoryDb.Put([]uint8(key), []uint8("value "+key))
+	}
+
+	t.Run("iterates in key order", func(t *testing.T) {
+		var keys []string
+		memoryDb.Iterate(nil, func(key, value []uint8) bool {
+			keys = append(keys, string(key))
+			return true
+		})
+
+		if !reflect.DeepEqual(keys, []string{"a1", "b1", "b2", "c1"}) {
+			t.Errorf("unexpected keys %v", keys)
+		}
+	})
+
+	t.Run("iterates a prefix, stopping when requested", func(t *testing.T) {
+		var keys []string
+		memoryDb.Iterate([]uint8("b"), func(key, value []uint8) bool {
+			if !reflect.DeepEqual(value, []uint8("value "+string(key))) {
+				t.Errorf("value mismatch for %s", key)
+			}
+			keys = append(keys, string(key))
+			return false
+		})
+
+		if !reflect.DeepEqual(keys, []string{"b1"}) {
+			t.Errorf("unexpected keys %v", keys)
+		}
+	})
+}
+
+func TestMemoryDBBatch(t *testing.T) {
+	memoryDb := NewMemoryDB(nil)
+	memoryDb.Open()
+	defer memoryDb.Close()
+
+	memoryDb.Put([]uint8("a"), []uint8("1"))
+
+	batch := memoryDb.NewBatch()
+	batch.Put([]uint8("b"), []uint8("2"))
+	batch.Del([]uint8("a"))
+
+	if memoryDb.Get([]uint8("b")) != nil {
+		t.Error("expected the batch to be pending until written")
+	}
+
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if memoryDb.Get([]uint8("a")) != nil {
+		t.Error("expected the key to be deleted")
+	}
+	if !reflect.DeepEqual(memoryDb.Get([]uint8("b")), []uint8("2")) {
+		t.Error("expected the key to be written")
+	}
+}
+
+func TestMemoryDBSnapshot(t *testing.T) {
+	memoryDb := NewMemoryDB(nil)
+	memoryDb.Put([]uint8("shared"), []uint8("one"))
+	memoryDb.Put([]uint8("deleted"), []uint8("two"))
+
+	snapshot := memoryDb.Snapshot()
+
+	t.Run("shares the contents", func(t *testing.T) {
+		if !reflect.DeepEqual(snapshot.Get([]uint8("shared")), []uint8("one")) {
+			t.Errorf("expected the shared value")
+		}
+	})
+
+	t.Run("isolates the writes", func(t *testing.T) {
+		snapshot.Put([]uint8("shared"), []uint8("changed"))
+		snapshot.Del([]uint8("deleted"))
+		memoryDb.Put([]uint8("added"), []uint8("three"))
+
+		if !reflect.DeepEqual(memoryDb.Get([]uint8("shared")), []uint8("one")) {
+			t.Errorf("expected the original value")
+		}
+		if !reflect.DeepEqual(memoryDb.Get([]uint8("deleted")), []uint8("two")) {
+			t.Errorf("expected the deleted value in the original")
+		}
+		if snapshot.Get([]uint8("deleted")) != nil || snapshot.Get([]uint8("added")) != nil {
+			t.Errorf("expected the snapshot to be isolated")
+		}
+	})
+
+	t.Run("iterates the merged layers", func(t *testing.T) {
+		var found []string
+		snapshot.Iterate(nil, func(key, value []uint8) bool {
+			found = append(found, string(key)+"="+string(value))
+			return true
+		})
+
+		if !reflect.DeepEqual(found, []string{"shared=changed"}) {
+			t.Errorf("unexpected entries %v", found)
+		}
+	})
+
+	t.Run("flattens deep chains", func(t *testing.T) {
+		fork := memoryDb
+		for i := 0; i < maxLayerDepth*2; i++ {
+			fork.Put([]uint8{uint8(i)}, []uint8{uint8(i)})
+			fork = fork.Snapshot()
+		}
+
+		if fork.base.depth > maxLayerDepth {
+			t.Errorf("expected at most %d layers, got %d", maxLayerDepth, fork.base.depth)
+		}
+		for i := 0; i < maxLayerDepth*2; i++ {
+			if !reflect.DeepEqual(fork.Get([]uint8{uint8(i)}), []uint8{uint8(i)}) {
+				t.Errorf("value mismatch for %d", i)
+			}
+		}
+		if !reflect.DeepEqual(fork.Get([]uint8("shared")), []uint8("one")) {
+			t.Errorf("expected the shared value")
+		}
+	})
+}
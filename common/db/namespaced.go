@@ -0,0 +1,167 @@
+package db
+
+import (
+	"errors"
+	"log"
+)
+
+// NamespacedDB is a view on the keys of a shared backing that start with a
+// prefix, giving every logical column of a node its own key space.
+// NOTE: the prefixes of the columns sharing a backing must not be prefixes of
+// one another, or the columns would see each others keys.
+// NOTE: the prefix counts against the key size of the backing, a FileFlatDB
+// stores keys of up to 64 bytes, so a prefix of up to 32 bytes leaves room
+// for hashes. Longer keys are dropped by Put and fail the batch Write.
+//
+// The backing is opened and closed by its owner, not by the columns. When the
+// backing is a TXDB, a Transaction on any of its columns spans all of them,
+// the writes to every column being committed together.
+type NamespacedDB struct {
+	backing BaseDB
+	prefix  []uint8
+}
+
+// Namespaced ...
+func Namespaced(backing BaseDB, prefix []uint8) *NamespacedDB {
+	return &NamespacedDB{
+		backing: backing,
+		prefix:  append([]uint8(nil), prefix...),
+	}
+}
+
+// Transaction runs fn in a transaction on the backing
+func (n *NamespacedDB) Transaction(fn func() bool) (bool, error) {
+	txdb, ok := n.backing.(TXDB)
+	if !ok {
+		return false, errors.New("backing does not support transactions")
+	}
+
+	return txdb.Transaction(fn)
+}
+
+// GetRoot ...
+func (n *NamespacedDB) GetRoot() []byte {
+	// NOTE: noop, the trie tracks its own root
+	return nil
+}
+
+// Close ...
+func (n *NamespacedDB) Close() {
+	// NOTE: noop, the backing is shared
+}
+
+// Open ...
+func (n *NamespacedDB) Open() {
+	// NOTE: noop, the backing is shared
+}
+
+// Drop ...
+func (n *NamespacedDB) Drop() {
+	n.Empty()
+}
+
+// Empty deletes the keys of this column only
+func (n *NamespacedDB) Empty() {
+	var keys [][]uint8
+	n.Iterate(nil, func(key, value []uint8) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	batch := n.NewBatch()
+	for _, key := range keys {
+		batch.Del(key)
+	}
+
+	if err := batch.Write(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Rename ...
+func (n *NamespacedDB) Rename(base, file string) {
+	log.Println("rename is not implemented")
+}
+
+// Maintain ...
+func (n *NamespacedDB) Maintain(fn *ProgressCB) error {
+	return n.backing.Maintain(fn)
+}
+
+// Size returns the total size of the keys and values in this column
+func (n *NamespacedDB) Size() int {
+	size := 0
+	n.Iterate(nil, func(key, value []uint8) bool {
+		size += len(key) + len(value)
+		return true
+	})
+
+	return size
+}
+
+// Del ...
+func (n *NamespacedDB) Del(key []uint8) {
+	n.backing.Del(n.key(key))
+}
+
+// Get ...
+func (n *NamespacedDB) Get(key []uint8) []uint8 {
+	return n.backing.Get(n.key(key))
+}
+
+// Put ...
+func (n *NamespacedDB) Put(key, value []uint8) {
+	n.backing.Put(n.key(key), value)
+}
+
+// Iterate walks the keys of this column, with the prefix removed
+func (n *NamespacedDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	iterable, ok := n.backing.(Iterable)
+	if !ok {
+		log.Println("iterate is not supported")
+		return
+	}
+
+	iterable.Iterate(n.key(prefix), func(key, value []uint8) bool {
+		return fn(key[len(n.prefix):], value)
+	})
+}
+
+// NewBatch returns a batch on the backing, atomic when the backing implements
+// Batcher
+func (n *NamespacedDB) NewBatch() Batch {
+	return &namespacedBatch{
+		db:    n,
+		batch: newBatch(n.backing),
+	}
+}
+
+// key ...
+func (n *NamespacedDB) key(key []uint8) []uint8 {
+	prefixed := make([]uint8, len(n.prefix)+len(key))
+	copy(prefixed, n.prefix)
+	copy(prefixed[len(n.prefix):], key)
+
+	return prefixed
+}
+
+// namespacedBatch ...
+type namespacedBatch struct {
+	db    *NamespacedDB
+	batch Batch
+}
+
+// Put ...
+func (b *namespacedBatch) Put(key, value []uint8) {
+	b.batch.Put(b.db.key(key), value)
+}
+
+// Del ...
+func (b *namespacedBatch) Del(key []uint8) {
+	b.batch.Del(b.db.key(key))
+}
+
+// Write ...
+func (b *namespacedBatch) Write() error {
+	return b.batch.Write()
+}
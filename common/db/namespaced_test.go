@@ -0,0 +1,88 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamespacedDB(t *testing.T) {
+	memoryDB := NewMemoryDB(&BaseOptions{})
+	baseDB := BaseDB(memoryDB)
+	txdb := NewTransactionDB(&baseDB)
+
+	headers := Namespaced(txdb, []uint8("h"))
+	bodies := Namespaced(txdb, []uint8("b"))
+
+	t.Run("isolates the key spaces", func(t *testing.T) {
+		headers.Put([]uint8("1"), []uint8("header"))
+		bodies.Put([]uint8("1"), []uint8("body"))
+
+		if !reflect.DeepEqual(headers.Get([]uint8("1")), []uint8("header")) {
+			t.Fail()
+		}
+		if !reflect.DeepEqual(bodies.Get([]uint8("1")), []uint8("body")) {
+			t.Fail()
+		}
+		if !reflect.DeepEqual(memoryDB.Get([]uint8("h1")), []uint8("header")) {
+			t.Fail()
+		}
+	})
+
+	t.Run("iterates and sizes a single column", func(t *testing.T) {
+		headers.Put([]uint8("2"), []uint8("header"))
+
+		var keys []string
+		headers.Iterate(nil, func(key, value []uint8) bool {
+			keys = append(keys, string(key))
+			return true
+		})
+
+		if !reflect.DeepEqual(keys, []string{"1", "2"}) {
+			t.Errorf("unexpected keys %v", keys)
+		}
+		if headers.Size() != 2*(1+len("header")) {
+			t.Errorf("unexpected size %d", headers.Size())
+		}
+	})
+
+	t.Run("empties a single column", func(t *testing.T) {
+		headers.Empty()
+
+		if headers.Get([]uint8("1")) != nil || headers.Size() != 0 {
+			t.Fail()
+		}
+		if !reflect.DeepEqual(bodies.Get([]uint8("1")), []uint8("body")) {
+			t.Fail()
+		}
+	})
+
+	t.Run("commits a transaction spanning columns", func(t *testing.T) {
+		ok, err := headers.Transaction(func() bool {
+			headers.Put([]uint8("3"), []uint8("header"))
+			bodies.Put([]uint8("3"), []uint8("body"))
+			return true
+		})
+		if err != nil || !ok {
+			t.Fail()
+		}
+
+		if memoryDB.Get([]uint8("h3")) == nil || memoryDB.Get([]uint8("b3")) == nil {
+			t.Error("expected both columns to be written")
+		}
+	})
+
+	t.Run("reverts a transaction spanning columns", func(t *testing.T) {
+		ok, err := bodies.Transaction(func() bool {
+			headers.Put([]uint8("4"), []uint8("header"))
+			bodies.Put([]uint8("4"), []uint8("body"))
+			return false
+		})
+		if err != nil || ok {
+			t.Fail()
+		}
+
+		if memoryDB.Get([]uint8("h4")) != nil || memoryDB.Get([]uint8("b4")) != nil {
+			t.Error("expected neither column to be written")
+		}
+	})
+}
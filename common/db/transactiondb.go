@@ -1,7 +1,10 @@
 package db
 
 import (
+	"bytes"
 	"errors"
+	"log"
+	"sort"
 )
 
 // KV ...
@@ -30,14 +33,16 @@ func NewTransactionDB(backing *BaseDB) *TransactionDB {
 	}
 }
 
-// Transaction ...
+// Transaction runs fn in a transaction, committed when fn returns true. A
+// failed commit is reverted and its error returned.
 func (t *TransactionDB) Transaction(fn func() bool) (bool, error) {
 	t.CreateTx()
 	result := fn()
 
 	if result {
 		if err := t.CommitTx(); err != nil {
-			return false, t.RevertTx()
+			t.RevertTx()
+			return false, err
 		}
 
 		return result, nil
@@ -80,6 +85,23 @@ func (t *TransactionDB) Maintain(fn *ProgressCB) error {
 	return t.Backing.Maintain(fn)
 }
 
+// Fork returns a TransactionDB on a fork of the backing, ErrNotForkable when
+// the backing isn't Forkable. The writes of an open transaction are not part
+// of the fork.
+func (t *TransactionDB) Fork() (BaseDB, error) {
+	forkable, ok := t.Backing.(Forkable)
+	if !ok {
+		return nil, ErrNotForkable
+	}
+
+	backing, err := forkable.Fork()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTransactionDB(&backing), nil
+}
+
 // Size ...
 func (t *TransactionDB) Size() int {
 	return t.Backing.Size()
@@ -125,6 +147,31 @@ func (t *TransactionDB) Put(key, value []uint8) {
 	t.Backing.Put(key, value)
 }
 
+// TryDel ...
+func (t *TransactionDB) TryDel(key []uint8) error {
+	if t.txStarted {
+		t.Del(key)
+		return nil
+	}
+
+	return tryDel(t.Backing, key)
+}
+
+// TryPut ...
+func (t *TransactionDB) TryPut(key, value []uint8) error {
+	if t.txStarted {
+		t.Put(key, value)
+		return nil
+	}
+
+	return tryPut(t.Backing, key, value)
+}
+
+// TryEmpty ...
+func (t *TransactionDB) TryEmpty() error {
+	return tryEmpty(t.Backing)
+}
+
 // CreateTx ...
 func (t *TransactionDB) CreateTx() error {
 	if t.txStarted {
@@ -142,14 +189,20 @@ func (t *TransactionDB) CommitTx() error {
 		return errors.New("cannot commit when not in transaction")
 	}
 
+	// NOTE: atomic when the backing implements Batcher
+	batch := newBatch(t.Backing)
 	for _, kv := range t.txOverlay {
 		if kv.Value == nil {
-			t.Backing.Del(kv.Key)
+			batch.Del(kv.Key)
 		} else {
-			t.Backing.Put(kv.Key, kv.Value)
+			batch.Put(kv.Key, kv.Value)
 		}
 	}
 
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
 	t.txOverlay = Overlay{}
 	t.txStarted = false
 	return nil
@@ -165,3 +218,63 @@ func (t *TransactionDB) RevertTx() error {
 	t.txStarted = false
 	return nil
 }
+
+// Iterate walks the backing store, with the values written in an open
+// transaction taking precedence over the stored ones
+func (t *TransactionDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	iterable, ok := t.Backing.(Iterable)
+	if !ok {
+		log.Println("iterate is not supported")
+		return
+	}
+
+	var pending []string
+	if t.txStarted {
+		for key := range t.txOverlay {
+			if bytes.HasPrefix([]byte(key), prefix) {
+				pending = append(pending, key)
+			}
+		}
+
+		sort.Strings(pending)
+	}
+
+	// emit sends the overlay entry, skipping deletions
+	emit := func(key string) bool {
+		kv := t.txOverlay[key]
+		if kv.Value == nil {
+			return true
+		}
+
+		return fn(kv.Key, kv.Value)
+	}
+
+	stopped := false
+	iterable.Iterate(prefix, func(key, value []uint8) bool {
+		for len(pending) > 0 && pending[0] < string(key) {
+			if stopped = !emit(pending[0]); stopped {
+				return false
+			}
+			pending = pending[1:]
+		}
+
+		if len(pending) > 0 && pending[0] == string(key) {
+			pending = pending[1:]
+			stopped = !emit(string(key))
+		} else {
+			stopped = !fn(key, value)
+		}
+
+		return !stopped
+	})
+
+	if stopped {
+		return
+	}
+
+	for _, key := range pending {
+		if !emit(key) {
+			return
+		}
+	}
+}
@@ -1,10 +1,32 @@
 package db
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
 
+var errBatchWrite = errors.New("batch write failed")
+
+// failingBatchDB is a MemoryDB whose batches fail to write
+type failingBatchDB struct {
+	*MemoryDB
+}
+
+type failingBatch struct{}
+
+func (f *failingBatchDB) NewBatch() Batch {
+	return &failingBatch{}
+}
+
+func (b *failingBatch) Put(key, value []uint8) {}
+
+func (b *failingBatch) Del(key []uint8) {}
+
+func (b *failingBatch) Write() error {
+	return errBatchWrite
+}
+
 func TestTransactionDB(t *testing.T) {
 	memoryDB := NewMemoryDB(&BaseOptions{})
 	baseDB := BaseDB(memoryDB)
@@ -100,4 +122,78 @@ func TestTransactionDB(t *testing.T) {
 			t.Fail()
 		}
 	})
+
+	t.Run("iterates the transaction merged with the backing", func(t *testing.T) {
+		memoryDB.Empty()
+		memoryDB.Put([]uint8("a"), []uint8("1"))
+		memoryDB.Put([]uint8("c"), []uint8("3"))
+		memoryDB.Put([]uint8("e"), []uint8("5"))
+
+		txdb.Transaction(func() bool {
+			txdb.Put([]uint8("b"), []uint8("2"))
+			txdb.Put([]uint8("c"), []uint8("updated"))
+			txdb.Del([]uint8("e"))
+			txdb.Put([]uint8("f"), []uint8("6"))
+
+			var entries []string
+			txdb.Iterate(nil, func(key, value []uint8) bool {
+				entries = append(entries, string(key)+"="+string(value))
+				return true
+			})
+
+			if !reflect.DeepEqual(entries, []string{"a=1", "b=2", "c=updated", "f=6"}) {
+				t.Errorf("unexpected entries %v", entries)
+			}
+
+			return false
+		})
+	})
+}
+
+func TestTransactionDBCommitError(t *testing.T) {
+	baseDB := BaseDB(&failingBatchDB{NewMemoryDB(&BaseOptions{})})
+	txdb := NewTransactionDB(&baseDB)
+
+	ok, err := txdb.Transaction(func() bool {
+		txdb.Put([]uint8("key"), []uint8("value"))
+		return true
+	})
+	if err != errBatchWrite {
+		t.Errorf("expected %v, got %v", errBatchWrite, err)
+	}
+	if ok {
+		t.Error("expected the transaction not to pass")
+	}
+
+	// NOTE: the failed transaction is reverted, a new one can start
+	if err := txdb.CreateTx(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionDBFork(t *testing.T) {
+	t.Run("forks a forkable backing", func(t *testing.T) {
+		baseDB := BaseDB(NewMemoryDB(&BaseOptions{}))
+		txdb := NewTransactionDB(&baseDB)
+		txdb.Put([]uint8("key"), []uint8("value"))
+
+		fork, err := txdb.Fork()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork.Put([]uint8("key"), []uint8("forked"))
+
+		if !reflect.DeepEqual(txdb.Get([]uint8("key")), []uint8("value")) {
+			t.Error("expected the fork writes to be isolated")
+		}
+	})
+
+	t.Run("refuses a backing that can't fork", func(t *testing.T) {
+		baseDB := BaseDB(NewLruDB(NewMemoryDB(&BaseOptions{}), 0))
+		txdb := NewTransactionDB(&baseDB)
+
+		if _, err := txdb.Fork(); err != ErrNotForkable {
+			t.Errorf("expected %v, got %v", ErrNotForkable, err)
+		}
+	})
 }
@@ -11,11 +11,22 @@ type DiskDB struct {
 }
 
 // NewDiskDB creates DiskDB database using LruDB for caching with FileFlatDB and extending TransactionDB.
+// The options (optional) are passed to every layer of the stack.
 func NewDiskDB(base, name string, options *db.BaseDBOptions) *DiskDB {
-	flatdb := fileflatdb.NewFileFlatDB(base, name)
+	lruSize := -1
+	if options != nil && options.LruSize > 0 {
+		lruSize = options.LruSize
+	}
+
+	flatdb := fileflatdb.NewFileFlatDB(base, name, options)
 	basedb := db.BaseDB(flatdb)
-	lrudb := db.NewLruDB(basedb, -1)
+	lrudb := db.NewLruDB(basedb, lruSize)
 	backingdb := db.BaseDB(lrudb)
+	if options != nil && options.ReadOnly {
+		// NOTE: a reader follows the writes of another process, it can't cache
+		backingdb = basedb
+	}
+
 	diskdb := &DiskDB{}
 	diskdb.Backing = backingdb
 	return diskdb
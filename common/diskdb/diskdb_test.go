@@ -1,9 +1,12 @@
 package db
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
@@ -64,6 +67,49 @@ func TestDiskDB(t *testing.T) {
 	diskDb.Close()
 }
 
+func TestDiskDBOptions(t *testing.T) {
+	value := []uint8(strings.Repeat("a compressible value ", 16))
+
+	sizes := map[db.Compression]int{}
+	for _, compression := range []db.Compression{db.CompressionNone, db.CompressionSnappy, db.CompressionZstd} {
+		setUp()
+
+		options := &db.BaseDBOptions{Compression: compression, LruSize: 16, Sync: db.SyncEveryCommit}
+		diskDb := NewDiskDB(getLocation(), "store.db", options)
+		diskDb.Open()
+		for i := 0; i < 32; i++ {
+			diskDb.Put([]uint8(fmt.Sprintf("key%d", i)), value)
+		}
+		diskDb.Close()
+
+		raw, err := ioutil.ReadFile(fmt.Sprintf("%s/store.db", getLocation()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compression != db.CompressionNone && bytes.Contains(raw, value) {
+			t.Errorf("expected compression %d to change the stored bytes", compression)
+		}
+		sizes[compression] = len(raw)
+
+		// NOTE: the codec is recorded in the file, read-only readers need no options
+		readOnly := NewDiskDB(getLocation(), "store.db", &db.BaseDBOptions{ReadOnly: true})
+		readOnly.Open()
+		if !reflect.DeepEqual(readOnly.Get([]uint8("key0")), value) {
+			t.Errorf("value mismatch for compression %d", compression)
+		}
+		if err := readOnly.TryPut([]uint8("key0"), value); err != db.ErrReadOnly {
+			t.Errorf("expected ErrReadOnly, got %v", err)
+		}
+		readOnly.Close()
+
+		cleanUp()
+	}
+
+	if sizes[db.CompressionSnappy] >= sizes[db.CompressionNone] || sizes[db.CompressionZstd] >= sizes[db.CompressionNone] {
+		t.Errorf("expected compressed files to be smaller, got %v", sizes)
+	}
+}
+
 func setUp() {
 	testpath := getLocation()
 	if _, err := os.Stat(testpath); os.IsNotExist(err) {
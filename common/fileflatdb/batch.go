@@ -0,0 +1,172 @@
+package fileflatdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/tsfdsong/go-polkadot/common/db"
+)
+
+var journalSuffix = ".journal"
+
+// Batch collects writes that are journaled before being applied, so a batch
+// interrupted halfway is completed when the database is next opened
+type Batch struct {
+	db  *FileFlatDB
+	ops []*db.KV
+}
+
+// NewBatch ...
+func (f *FileFlatDB) NewBatch() db.Batch {
+	return &Batch{db: f}
+}
+
+// Put ...
+func (b *Batch) Put(key, value []uint8) {
+	b.ops = append(b.ops, &db.KV{Key: key, Value: value})
+}
+
+// Del ...
+func (b *Batch) Del(key []uint8) {
+	b.ops = append(b.ops, &db.KV{Key: key, Value: nil})
+}
+
+// Write ...
+func (b *Batch) Write() error {
+	f := b.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file.handle == nil {
+		return errors.New("[fileflatdb/batch] expected an open database")
+	}
+	if f.file.readOnly {
+		return db.ErrReadOnly
+	}
+	for _, kv := range b.ops {
+		if len(kv.Key) > f.serializer.MaxKeySize() {
+			return ErrKeyTooLarge
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(b.ops); err != nil {
+		return err
+	}
+
+	// NOTE: the rename commits the batch
+	journal := f.journalPath()
+	if err := writeFileSync(journal+".tmp", buf.Bytes()); err != nil {
+		return err
+	}
+	if err := os.Rename(journal+".tmp", journal); err != nil {
+		return err
+	}
+	if err := syncDir(journal); err != nil {
+		return err
+	}
+
+	f.applyBatch(b.ops)
+	b.ops = nil
+
+	if err := os.Remove(journal); err != nil {
+		return err
+	}
+
+	// NOTE: a journal left behind would be replayed over later writes
+	return syncDir(journal)
+}
+
+// applyBatch writes the values and flushes the file, the caller holds the lock
+func (f *FileFlatDB) applyBatch(ops []*db.KV) {
+	for _, kv := range ops {
+		if kv.Value == nil {
+			f.put(kv.Key, []uint8{})
+		} else {
+			f.put(kv.Key, kv.Value)
+		}
+	}
+
+	f.file.Sync()
+}
+
+// replayJournal applies a batch that was committed but not completed, the
+// caller holds the lock
+func (f *FileFlatDB) replayJournal() {
+	journal := f.journalPath()
+	if !f.file.readOnly {
+		os.Remove(journal + ".tmp")
+	}
+
+	data, err := ioutil.ReadFile(journal)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if f.file.readOnly {
+		log.Printf("[fileflatdb/batch] %s has an incomplete batch, open it for writing to complete it", f.file.path)
+		return
+	}
+
+	var ops []*db.KV
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&ops); err != nil {
+		log.Fatalf("[fileflatdb/batch] corrupt journal %s: %v", journal, err)
+	}
+
+	// NOTE: writes applied before the interruption are simply repeated
+	f.applyBatch(ops)
+
+	if err := os.Remove(journal); err != nil {
+		log.Fatal(err)
+	}
+	if err := syncDir(journal); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// journalPath ...
+func (f *FileFlatDB) journalPath() string {
+	return f.file.path + journalSuffix
+}
+
+// writeFileSync writes data to file, flushing it to stable storage
+func writeFileSync(file string, data []byte) error {
+	handle, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := handle.Write(data); err != nil {
+		handle.Close()
+		return err
+	}
+	if err := handle.Sync(); err != nil {
+		handle.Close()
+		return err
+	}
+
+	return handle.Close()
+}
+
+// syncDir flushes the directory holding file, so a rename or a removal of
+// file is on stable storage
+func syncDir(file string) error {
+	handle, err := os.Open(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	if err := handle.Sync(); err != nil {
+		handle.Close()
+		return err
+	}
+
+	return handle.Close()
+}
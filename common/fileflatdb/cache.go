@@ -2,7 +2,8 @@ package fileflatdb
 
 import (
 	"log"
-	"os"
+
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 )
 
 // LruMap ...
@@ -10,9 +11,14 @@ type LruMap map[int64][]byte
 
 // Cache ...
 type Cache struct {
-	file      *File
-	lruBranch LruMap
-	lruData   LruMap
+	file         *File
+	lruBranch    LruMap
+	lruData      LruMap
+	branchHits   *metrics.Counter
+	branchMisses *metrics.Counter
+	dataHits     *metrics.Counter
+	dataMisses   *metrics.Counter
+	bytesRead    *metrics.Counter
 }
 
 // NewCache ...
@@ -24,25 +30,52 @@ func NewCache(file *File) *Cache {
 	}
 }
 
+// SetMetrics registers the cache hits and misses and the bytes read from the
+// file as <name>_<metric>. Reads served by the mapping count as hits.
+func (c *Cache) SetMetrics(registry *metrics.Registry, name string) {
+	c.branchHits = registry.Counter(name + "_branch_hits")
+	c.branchMisses = registry.Counter(name + "_branch_misses")
+	c.dataHits = registry.Counter(name + "_data_hits")
+	c.dataMisses = registry.Counter(name + "_data_misses")
+	c.bytesRead = registry.Counter(name + "_bytes_read")
+}
+
 // CacheBranch ...
 func (c *Cache) CacheBranch(branchAt int64, branch []byte) {
+	// NOTE: the mapping already acts as the cache, read-only files are updated
+	// in place by the writer
+	if c.file.IsMapped() || c.file.readOnly {
+		return
+	}
+
 	c.lruBranch[branchAt] = branch
 }
 
 // CacheData ...
 func (c *Cache) CacheData(dataAt int64, data []byte) {
+	if c.file.IsMapped() || c.file.readOnly {
+		return
+	}
+
 	c.lruData[dataAt] = data
 }
 
 // GetCachedBranch ...
 func (c *Cache) GetCachedBranch(branchAt int64) []byte {
+	if c.file.IsMapped() {
+		c.branchHits.Inc()
+		return c.file.ReadMapped(branchAt, int64(branchSize))
+	}
+
 	branch, found := c.lruBranch[branchAt]
-	if !found {
+	if found {
+		c.branchHits.Inc()
+	} else {
+		c.branchMisses.Inc()
+		c.bytesRead.Add(int64(branchSize))
 		branch = make([]byte, branchSize)
-		f := c.openFile()
-		defer f.Close()
 
-		if _, err := f.ReadAt(branch, branchAt); err != nil {
+		if err := c.file.ReadAt(branch, branchAt); err != nil {
 			log.Fatalf("[fileflatdb/cache] get cached branch error: %v", err)
 		}
 
@@ -54,14 +87,20 @@ func (c *Cache) GetCachedBranch(branchAt int64) []byte {
 
 // GetCachedData ...
 func (c *Cache) GetCachedData(dataAt int64, length int64) []byte {
+	if c.file.IsMapped() {
+		c.dataHits.Inc()
+		return c.file.ReadMapped(dataAt, length)
+	}
+
 	data, found := c.lruData[dataAt]
-	if !found {
+	if found {
+		c.dataHits.Inc()
+	} else {
+		c.dataMisses.Inc()
+		c.bytesRead.Add(length)
 		data = make([]byte, length)
 
-		f := c.openFile()
-		defer f.Close()
-
-		if _, err := f.ReadAt(data, dataAt); err != nil {
+		if err := c.file.ReadAt(data, dataAt); err != nil {
 			log.Fatalf("[fileflatdb/cache] get cached data error: %v", err)
 		}
 
@@ -70,24 +109,3 @@ func (c *Cache) GetCachedData(dataAt int64, length int64) []byte {
 
 	return data
 }
-
-// openFile ...
-func (c *Cache) openFile() *os.File {
-	filepath := c.file.path
-
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		log.Fatalf("[fileflatdb/cache] file %q does not exist", filepath)
-	}
-
-	file, err := os.OpenFile(filepath, os.O_RDWR, 0755)
-	if err != nil {
-		log.Fatalf("[fileflatdb/cache] error opening file: %v", err)
-	}
-
-	return file
-}
-
-// fd ...
-func (c *Cache) fd() uintptr {
-	return c.file.fd
-}
@@ -1,245 +1,237 @@
 package fileflatdb
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
 	"math/big"
 	"os"
-	"syscall"
+	"path/filepath"
 	"time"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
 )
 
-// Compact ...
+var errClosedDuringCompact = errors.New("[fileflatdb/compact] database closed while compacting")
+
+// Compact copies the live entries of an open FileFlatDB into a new file and
+// swaps it in place of the old one. Writes made while the copy is running are
+// logged by FileFlatDB.Put and replayed before the swap.
 type Compact struct {
-	fd   int64
-	file string
+	db      *FileFlatDB
+	target  *File
+	impl    *Impl
+	keys    int
+	percent float64
 }
 
 // NewCompact ...
-func NewCompact(file string) *Compact {
+func NewCompact(f *FileFlatDB) *Compact {
 	return &Compact{
-		fd:   -1,
-		file: file,
+		db: f,
 	}
 }
 
 // Maintain ...
-func (c *Compact) Maintain(fn *db.ProgressCB) {
-	if c.fd != -1 {
-		log.Fatalln("[fileflatdb/compact] database cannot be open for compacting")
+func (c *Compact) Maintain(fn *db.ProgressCB) error {
+	var cb db.ProgressCB
+	if fn != nil {
+		cb = *fn
 	}
 
 	start := time.Now().Unix()
-	newFile := fmt.Sprintf("%s.compacted", c.file)
-	newFd := c.Open(newFile, true)
-	oldFd := c.Open(c.file, false)
-	keys := c.Compact(*fn, newFd, oldFd)
-
-	closeFd(oldFd)
-	closeFd(newFd)
-
-	newStat, err := os.Stat(newFile)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to stat new file: %s\n", err)
+	if err := c.begin(); err != nil {
+		return err
 	}
-	oldStat, err := os.Stat(c.file)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to stat old file: %s\n", err)
-	}
-	percentage := 100 * (newStat.Size() / oldStat.Size())
-	sizeMB := newStat.Size() / (1024 * 1024)
-	elapsed := time.Now().Unix() - start
 
-	err = os.Remove(c.file)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to remove file: %s\n", err)
+	if err := c.compactBranch(cb, c.db.file.rootAt, 0); err != nil {
+		c.abort()
+		return err
 	}
-	err = os.Rename(newFile, c.file)
+
+	oldSize, newSize, err := c.finish()
 	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to rename file: %s\n", err)
+		return err
 	}
 
-	log.Printf("compacted in %d, %dk keys, %dMB (%d%%)", elapsed, keys/1e3, sizeMB, percentage)
-}
+	percentage := 100 * float64(newSize) / float64(oldSize)
+	sizeMB := newSize / (1024 * 1024)
+	elapsed := time.Now().Unix() - start
 
-// Open ...
-func (c *Compact) Open(file string, startEmpty bool) uintptr {
-	_, err := os.Stat(file)
-	isExisting := !os.IsNotExist(err)
-	if !isExisting || startEmpty {
-		data := make([]byte, branchSize)
-		err := ioutil.WriteFile(file, data, os.ModePerm)
-		if err != nil {
-			log.Fatalf("[fileflatdb/compact] failed write to file after opening: %s\n", err)
-		}
-	}
+	log.Printf("compacted in %d, %dk keys, %dMB (%.1f%%)", elapsed, c.keys/1e3, sizeMB, percentage)
 
-	f, err := os.OpenFile(file, os.O_RDONLY, 0755)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed write to open file: %s\n", err)
+	if cb != nil {
+		cb(&db.ProgressValue{
+			IsCompleted: true,
+			Keys:        c.keys,
+			Percent:     100,
+		})
 	}
 
-	return f.Fd()
+	return nil
 }
 
-// doCompact ...
-func (c *Compact) doCompact(keys *int, percent int, fn db.ProgressCB, newFd, oldFd uintptr, newAt int, oldAt int, depth int) {
-	increment := (100 / float64(entryNum)) / math.Pow(float64(entryNum), float64(depth))
-
-	for index := 0; index < entryNum; index++ {
-		entry := c.CompactReadEntry(oldFd, oldAt, index)
-		dataAt := new(big.Int)
-		dataAt.SetBytes(entry[1 : 1+uintSize])
-		entryType := entry[0]
-
-		if int(entryType) == SlotEmpty {
-			percent += int(increment)
-		} else if int(entryType) == SlotLeaf {
-			key, value := c.CompactReadKey(oldFd, int64(dataAt.Uint64()))
-			keyAt := c.CompactWriteKey(newFd, key, value)
+// begin creates the target file and starts logging writes on the database
+func (c *Compact) begin() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
 
-			c.CompactUpdateLink(newFd, newAt, index, keyAt, SlotLeaf)
-
-			newKeys := *keys + 1
-			keys = &newKeys
-			percent += int(increment)
-		} else if int(entryType) == SlotBranch {
-			headerAt := c.CompactWriteHeader(newFd, newAt, index)
+	if c.db.compactLog != nil {
+		return errors.New("[fileflatdb/compact] compaction already running")
+	}
 
-			c.doCompact(keys, percent, fn, newFd, oldFd, int(headerAt), int(dataAt.Uint64()), depth+1)
-		} else {
-			log.Fatalf("[fileflatdb/compact] unknown entry type %d\n", entryType)
-		}
+	path := c.db.file.path
+	options := &db.BaseDBOptions{Compression: c.db.file.compression}
+	c.target = NewFile(filepath.Dir(path), fmt.Sprintf("%s.compacted", filepath.Base(path)), options)
+	// NOTE: a leftover from an interrupted run is of no use, start from scratch
+	os.Remove(c.target.path)
+	// NOTE: the target is private to the compaction, it needs no writer lock
+	c.target.locking = false
+	c.target.Open(c.target.path, true)
+	c.impl = NewImpl(NewCache(c.target))
+	c.db.compactLog = make(map[string][]byte)
 
-		var isCompleted bool
-		if depth == 0 && index == entryNum-1 {
-			isCompleted = true
-		}
-
-		if fn != nil {
-			fn(&db.ProgressValue{
-				IsCompleted: isCompleted,
-				Keys:        *keys,
-				Percent:     percent,
-			})
-		}
-	}
+	return nil
 }
 
-// Compact ...
-func (c *Compact) Compact(fn db.ProgressCB, newFd, oldFd uintptr) int {
-	var keys int
-	var percent int
-
-	c.doCompact(&keys, percent, fn, newFd, oldFd, 0, 0, 0)
+// abort drops the target file and stops logging writes
+func (c *Compact) abort() {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
 
-	return keys
+	c.db.compactLog = nil
+	c.target.Close()
+	os.Remove(c.target.path)
 }
 
-// CompactReadEntry ...
-func (c *Compact) CompactReadEntry(fd uintptr, at int, index int) []byte {
-	entry := make([]byte, entrySize)
-	entryAt := at + (index * entrySize)
+// finish replays the logged writes into the target and swaps the files
+func (c *Compact) finish() (int64, int64, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
 
-	file := os.NewFile(fd, "temp")
-	_, err := file.ReadAt(entry, int64(entryAt))
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to read entry: %s\n", err)
-	}
+	pending := c.db.compactLog
+	c.db.compactLog = nil
 
-	return entry
-}
+	if c.db.file.handle == nil {
+		c.target.Close()
+		os.Remove(c.target.path)
+		return 0, 0, errClosedDuringCompact
+	}
 
-// CompactReadKey ...
-func (c *Compact) CompactReadKey(fd uintptr, at int64) ([]byte, []byte) {
-	key := make([]byte, keyTotalSize)
-	file := os.NewFile(fd, "temp")
-	_, err := file.ReadAt(key, at)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to read key: %s\n", err)
+	for key, value := range pending {
+		c.write([]byte(key), value)
 	}
 
-	valueLength := new(big.Int)
-	valueLength.SetBytes(key[keySize : keySize+uintSize])
+	oldSize := c.db.file.fileSize
+	newSize := c.target.fileSize
 
-	valueAt := new(big.Int)
-	valueAt.SetBytes(key[(keySize + uintSize) : (keySize+uintSize)+uintSize])
+	c.target.Sync()
+	c.target.Close()
+	// NOTE: the writer lock is kept across the swap
+	c.db.file.closeHandle()
 
-	value := make([]byte, valueLength.Uint64())
-	_, err = file.ReadAt(value, int64(valueAt.Uint64()))
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to read value: %s\n", err)
+	if err := os.Rename(c.target.path, c.db.file.path); err != nil {
+		c.db.file.Open(c.db.file.path, false)
+		return 0, 0, fmt.Errorf("[fileflatdb/compact] failed to swap files: %v", err)
 	}
 
-	return key, value
+	c.db.file.Open(c.db.file.path, false)
+	c.db.cache.lruBranch = make(LruMap)
+	c.db.cache.lruData = make(LruMap)
+
+	return oldSize, newSize, nil
 }
 
-// CompactWriteKey ...
-func (c *Compact) CompactWriteKey(fd uintptr, key, value []byte) int64 {
-	file := os.NewFile(fd, "temp")
-	stat, err := file.Stat()
+// compactBranch walks the entries of the branch at branchAt, copying every
+// leaf into the target
+func (c *Compact) compactBranch(fn db.ProgressCB, branchAt int64, depth int) error {
+	increment := (100 / float64(entryNum)) / math.Pow(float64(entryNum), float64(depth))
+
+	branch, err := c.readBranch(branchAt)
 	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to stat file: %s\n", err)
+		return err
 	}
-	valueAt := stat.Size()
-	keyAt := valueAt + int64(len(value))
 
-	writeUIntBE(key, int64(valueAt), int64(keySize)+int64(uintSize), int64(uintSize))
+	for index := 0; index < entryNum; index++ {
+		entry := branch[index*entrySize : (index+1)*entrySize]
+		pointer := new(big.Int)
+		pointer.SetBytes(entry[1 : 1+uintSize])
+		entryType := entry[0]
 
-	_, err = file.WriteAt(value, valueAt)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to write value: %s\n", err)
-	}
-	_, err = file.WriteAt(key, keyAt)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to compact write key: %s\n", err)
+		switch int(entryType) {
+		case SlotEmpty:
+			c.percent += increment
+		case SlotLeaf:
+			key, value, err := c.readLeaf(int64(pointer.Uint64()))
+			if err != nil {
+				return err
+			}
+
+			// NOTE: deleted keys are stored with an empty value, drop them
+			if len(c.db.serializer.DeserializeValue(value)) != 0 {
+				c.write(key, value)
+				c.keys++
+			}
+			c.percent += increment
+		case SlotBranch:
+			if err := c.compactBranch(fn, int64(pointer.Uint64()), depth+1); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("[fileflatdb/compact] unknown entry type %d", entryType)
+		}
+
+		if fn != nil {
+			fn(&db.ProgressValue{
+				IsCompleted: false,
+				Keys:        c.keys,
+				Percent:     c.percent,
+			})
+		}
 	}
 
-	return keyAt
+	return nil
 }
 
-// CompactUpdateLink ...
-func (c *Compact) CompactUpdateLink(fd uintptr, at int, index int, pointer int64, kind int) {
-	entry := make([]byte, entrySize)
-	entryAt := at + (index * entrySize)
-
-	entry[0] = byte(kind)
-	writeUIntBE(entry, int64(pointer), int64(1), int64(uintSize))
+// readBranch returns a copy of the branch at branchAt
+func (c *Compact) readBranch(branchAt int64) ([]byte, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
 
-	file := os.NewFile(fd, "temp")
-	_, err := file.WriteAt(entry, int64(entryAt))
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to compact update link write entry: %s\n", err)
+	if c.db.file.handle == nil {
+		return nil, errClosedDuringCompact
 	}
+
+	return append([]byte(nil), c.db.cache.GetCachedBranch(branchAt)...), nil
 }
 
-// CompactWriteHeader ...
-func (c *Compact) CompactWriteHeader(fd uintptr, at int, index int) int64 {
-	file := os.NewFile(fd, "temp")
-	stat, err := file.Stat()
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to stat file: %s\n", err)
-	}
-	headerAt := stat.Size()
+// readLeaf returns copies of the key and serialized value stored at keyAt
+func (c *Compact) readLeaf(keyAt int64) ([]byte, []byte, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
 
-	header := make([]byte, branchSize)
-	_, err = file.WriteAt(header, headerAt)
-	if err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to write header: %s\n", err)
+	if c.db.file.handle == nil {
+		return nil, nil, errClosedDuringCompact
 	}
 
-	c.CompactUpdateLink(fd, at, index, headerAt, SlotBranch)
+	keyValue := c.db.impl.GetKeyValue(keyAt)
+	value := c.db.impl.ReadValue(keyValue)
 
-	return headerAt
+	return append([]byte(nil), c.db.serializer.DeserializeKey(keyValue)...), append([]byte(nil), value.Value...), nil
 }
 
-func closeFd(fd uintptr) {
-	// close file descriptor
-	if err := syscall.Close(int(fd)); err != nil {
-		log.Fatalf("[fileflatdb/compact] failed to close file descriptor: %s\n", err)
+// write stores the serialized value under key in the target. Values from an
+// older version are re-serialized, which migrates it to the current format.
+func (c *Compact) write(key, value []byte) {
+	if c.db.file.version != c.target.version {
+		value = c.target.serializer.SerializeValue(c.db.serializer.DeserializeValue(value))
 	}
+
+	k := c.impl.FindKey(c.target.serializer.SerializeKey(key), true, 0, c.target.rootAt)
+	if k == nil {
+		log.Fatal("[fileflatdb/compact] unable to create key")
+	}
+
+	c.impl.WriteValue(int(k.KeyAt), k.KeyValue, value)
 }
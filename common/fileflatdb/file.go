@@ -5,8 +5,8 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"strings"
-	"syscall"
 	"time"
 
 	db "github.com/tsfdsong/go-polkadot/common/db"
@@ -15,21 +15,47 @@ import (
 
 var uintSize = 5
 var keySize = 32
-var keyTotalSize = keySize + uintSize + uintSize
 var entryNum = 16 // nibbles, 256 for bytes (where serialize would be noop)
 var entrySize = 1 + uintSize
 var branchSize = entryNum * entrySize
 var defaultFile = "store.db"
 var lruBranchCount = 16384 // * 96 = bytes
 var lruDataCount = 8192
+var defaultSyncInterval = time.Second
+var mmapMinSize int64 = 64 * 1024 * 1024
+
+// The file starts with a header recording the format version and the codec
+// new values are written with, followed by the root branch. Files written
+// before the header was introduced start with the root branch directly and
+// keep their layout, see Serializer.IsLegacy.
+var headerMagic = []byte("ffdb")
+var lockSuffix = ".lock"
+var headerSize = 16
+var formatVersion byte = 1
 
 // File ...
 type File struct {
-	serializer *Serializer
-	fd         uintptr
-	fileSize   int64
-	path       string
-	file       string
+	serializer   *Serializer
+	compression  db.Compression
+	version      byte
+	rootAt       int64
+	handle       *os.File
+	fileSize     int64
+	path         string
+	file         string
+	sync         db.SyncPolicy
+	syncInterval time.Duration
+	lastSync     time.Time
+	useMmap      bool
+	readOnly     bool
+	mustExist    bool
+	locking      bool
+	lock         *os.File
+	mapping      []byte
+	retired      [][]byte
+	// NOTE: bumped every time the file is opened, a walk holding offsets into
+	// the file must restart once it changed, see FileFlatDB.Iterate
+	generation uint64
 }
 
 // NewFile ...
@@ -38,24 +64,52 @@ func NewFile(base, file string, options *db.BaseDBOptions) *File {
 		file = defaultFile
 	}
 
-	var isCompressed bool
-	if options != nil && options.IsCompressed {
-		isCompressed = true
+	var isCompressed, useMmap, readOnly, mustExist bool
+	compression := db.CompressionNone
+	sync := db.SyncNever
+	syncInterval := defaultSyncInterval
+	if options != nil {
+		isCompressed = options.IsCompressed
+		compression = options.Compression
+		if isCompressed && compression == db.CompressionNone {
+			compression = db.CompressionSnappy
+		}
+		sync = options.Sync
+		readOnly = options.ReadOnly
+		mustExist = options.ErrorIfMissing || options.ReadOnly
+		if options.SyncInterval > 0 {
+			syncInterval = options.SyncInterval
+		}
+		if options.UseMmap {
+			if mmapSupported {
+				useMmap = true
+			} else {
+				log.Println("[fileflatdb] mmap is not supported, using file reads")
+			}
+		}
 	}
 
 	filepath := dirutil.NormalizePath(fmt.Sprintf("%s/%s", base, file))
 
 	f := &File{
-		serializer: NewSerializer(),
-		fd:         0,
-		fileSize:   0,
-		path:       filepath,
-		file:       file,
+		serializer:   NewSerializer(),
+		compression:  compression,
+		handle:       nil,
+		fileSize:     0,
+		path:         filepath,
+		file:         file,
+		sync:         sync,
+		syncInterval: syncInterval,
+		useMmap:      useMmap,
+		readOnly:     readOnly,
+		mustExist:    mustExist,
+		locking:      !readOnly,
 	}
 
 	f.serializer.IsCompressed = isCompressed
+	f.serializer.Compression = compression
 
-	if _, err := os.Stat(base); os.IsNotExist(err) {
+	if _, err := os.Stat(base); os.IsNotExist(err) && !mustExist {
 		if err := os.MkdirAll(base, os.ModePerm); err != nil {
 			log.Fatal(err)
 		}
@@ -68,9 +122,9 @@ func NewFile(base, file string, options *db.BaseDBOptions) *File {
 func (f *File) AssertOpen(open bool) {
 	var test bool
 	if open {
-		test = f.fd != 0
+		test = f.handle != nil
 	} else {
-		test = f.fd == 0
+		test = f.handle == nil
 	}
 
 	if !test {
@@ -84,12 +138,23 @@ func (f *File) AssertOpen(open bool) {
 
 // Close ...
 func (f *File) Close() {
-	// close file descriptor
-	if err := syscall.Close(int(f.fd)); err != nil {
+	f.closeHandle()
+	f.unlock()
+}
+
+// closeHandle closes the file, keeping the writer lock
+func (f *File) closeHandle() {
+	if f.sync != db.SyncNever && !f.readOnly {
+		f.Sync()
+	}
+
+	f.unmap()
+
+	if err := f.handle.Close(); err != nil {
 		log.Fatal(err)
 	}
 
-	f.fd = 0
+	f.handle = nil
 }
 
 // Open ...
@@ -99,12 +164,25 @@ func (f *File) Open(filepath string, startEmpty bool) {
 	_, err := os.Stat(filepath)
 	isExisting := !os.IsNotExist(err)
 
+	if !isExisting && f.mustExist {
+		log.Fatalf("[fileflatdb] %s does not exist", filepath)
+	}
+	if startEmpty && f.readOnly {
+		log.Fatal(db.ErrReadOnly)
+	}
+
+	if f.locking && f.lock == nil {
+		if err := f.tryLock(filepath); err != nil {
+			log.Fatalf("[fileflatdb] %v", err)
+		}
+	}
+
 	if !isExisting || startEmpty {
 		if isExisting {
 			os.Rename(filepath, fmt.Sprintf("%s.%d", filepath, time.Now().Unix()))
 		}
 
-		b := make([]byte, branchSize)
+		b := append(f.newHeader(), make([]byte, branchSize)...)
 
 		paths := strings.Split(filepath, "/")
 		folderPath := strings.Join(paths[:len(paths)-1], "/")
@@ -116,16 +194,214 @@ func (f *File) Open(filepath string, startEmpty bool) {
 		ioutil.WriteFile(filepath, b, 0644)
 	}
 
-	file, err := os.OpenFile(filepath, os.O_RDWR, 0755)
+	flag := os.O_RDWR
+	if f.readOnly {
+		flag = os.O_RDONLY
+	}
+
+	file, err := os.OpenFile(filepath, flag, 0755)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	stat, err := os.Stat(filepath)
+	stat, err := file.Stat()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	f.fd = file.Fd()
+	f.handle = file
 	f.fileSize = stat.Size()
+	f.lastSync = time.Now()
+	f.generation++
+	f.readHeader()
+
+	if f.useMmap {
+		f.remap(f.fileSize)
+	}
+}
+
+// tryLock takes the writer lock on the lock file next to filepath, failing
+// when another writer holds it. Readers don't lock.
+func (f *File) tryLock(filepath string) error {
+	if err := os.MkdirAll(path.Dir(filepath), os.ModePerm); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(filepath+lockSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	f.lock = lock
+	if err := f.flock(); err != nil {
+		lock.Close()
+		f.lock = nil
+		return fmt.Errorf("%s is locked by another writer: %v", filepath, err)
+	}
+
+	return nil
+}
+
+// unlock releases the writer lock
+func (f *File) unlock() {
+	if f.lock == nil {
+		return
+	}
+
+	if err := f.funlock(); err != nil {
+		log.Fatalf("[fileflatdb] failed to unlock: %v", err)
+	}
+	if err := f.lock.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	f.lock = nil
+}
+
+// Refresh lets a read-only file see the writes made since it was opened,
+// picking up the size appended to and the new file swapped in by compaction
+func (f *File) Refresh() {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	current, err := f.handle.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !os.SameFile(stat, current) {
+		f.closeHandle()
+		f.Open(f.path, false)
+		return
+	}
+
+	f.fileSize = stat.Size()
+}
+
+// newHeader ...
+func (f *File) newHeader() []byte {
+	header := make([]byte, headerSize)
+	copy(header, headerMagic)
+	header[len(headerMagic)] = formatVersion
+	header[len(headerMagic)+1] = byte(f.compression)
+
+	return header
+}
+
+// readHeader detects the file format, headerless files are read as version 0
+// with values serialized according to the options the file was opened with
+func (f *File) readHeader() {
+	header := make([]byte, headerSize)
+	if f.fileSize < int64(headerSize+branchSize) {
+		header = nil
+	} else if err := f.ReadAt(header, 0); err != nil {
+		log.Fatalf("[fileflatdb] failed to read header: %v", err)
+	}
+
+	if header == nil || string(header[:len(headerMagic)]) != string(headerMagic) {
+		f.version = 0
+		f.rootAt = 0
+		f.serializer.IsLegacy = true
+		return
+	}
+
+	f.version = header[len(headerMagic)]
+	if f.version > formatVersion {
+		log.Fatalf("[fileflatdb] unsupported format version %d", f.version)
+	}
+
+	f.compression = db.Compression(header[len(headerMagic)+1])
+	f.rootAt = int64(headerSize)
+	f.serializer.IsLegacy = false
+	f.serializer.Compression = f.compression
+}
+
+// IsMapped returns true when reads are served from a memory mapping
+func (f *File) IsMapped() bool {
+	return f.mapping != nil
+}
+
+// ReadMapped returns length bytes at offset at straight from the mapping,
+// growing the mapping when the file has been extended past it
+func (f *File) ReadMapped(at, length int64) []byte {
+	if at+length > int64(len(f.mapping)) {
+		f.remap(at + length)
+	}
+
+	return f.mapping[at : at+length : at+length]
+}
+
+// remap maps at least size bytes of the file. Previous mappings are retired
+// rather than released, since slices handed out by ReadMapped may still
+// point into them. They are released on Close.
+func (f *File) remap(size int64) {
+	length := size * 2
+	if length < mmapMinSize {
+		length = mmapMinSize
+	}
+
+	pageSize := int64(os.Getpagesize())
+	length = (length + pageSize - 1) / pageSize * pageSize
+
+	mapping, err := f.mmap(length)
+	if err != nil {
+		log.Fatalf("[fileflatdb] failed to map file: %v", err)
+	}
+
+	if f.mapping != nil {
+		f.retired = append(f.retired, f.mapping)
+	}
+
+	f.mapping = mapping
+}
+
+// unmap releases the current and all retired mappings
+func (f *File) unmap() {
+	if f.mapping != nil {
+		f.retired = append(f.retired, f.mapping)
+	}
+
+	for _, mapping := range f.retired {
+		if err := munmap(mapping); err != nil {
+			log.Fatalf("[fileflatdb] failed to unmap file: %v", err)
+		}
+	}
+
+	f.mapping = nil
+	f.retired = nil
+}
+
+// ReadAt reads len(b) bytes from the open file starting at offset at
+func (f *File) ReadAt(b []byte, at int64) error {
+	_, err := f.handle.ReadAt(b, at)
+	return err
+}
+
+// WriteAt writes b to the open file starting at offset at
+func (f *File) WriteAt(b []byte, at int64) error {
+	_, err := f.handle.WriteAt(b, at)
+	return err
+}
+
+// Commit marks the end of a logical write, flushing according to the sync policy
+func (f *File) Commit() {
+	switch f.sync {
+	case db.SyncEveryCommit:
+		f.Sync()
+	case db.SyncPeriodic:
+		if time.Since(f.lastSync) >= f.syncInterval {
+			f.Sync()
+		}
+	}
+}
+
+// Sync flushes the file contents to stable storage
+func (f *File) Sync() {
+	if err := f.handle.Sync(); err != nil {
+		log.Fatalf("[fileflatdb] failed to sync file: %v", err)
+	}
+
+	f.lastSync = time.Now()
 }
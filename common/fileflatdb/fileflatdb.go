@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 )
 
 // SlotEmpty ...
@@ -23,31 +25,51 @@ type FileFlatDB struct {
 	cache      *Cache
 	file       *File
 	serializer *Serializer
+	mu         sync.Mutex
+	// NOTE: non-nil while an online compaction is copying entries, maps the
+	// serialized key to the serialized value of every write made meanwhile
+	compactLog map[string][]byte
 }
 
 // NewFileFlatDB ...
-func NewFileFlatDB(base, file string) *FileFlatDB {
-	fileInstance := NewFile(base, file, nil)
+func NewFileFlatDB(base, file string, options *db.BaseDBOptions) *FileFlatDB {
+	fileInstance := NewFile(base, file, options)
 	cacheInstance := NewCache(fileInstance)
 	return &FileFlatDB{
 		impl:       NewImpl(cacheInstance),
 		cache:      cacheInstance,
 		file:       fileInstance,
-		serializer: NewSerializer(),
+		serializer: fileInstance.serializer,
 	}
 }
 
+// SetMetrics registers the cache and write metrics as <name>_<metric>
+func (f *FileFlatDB) SetMetrics(registry *metrics.Registry, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cache.SetMetrics(registry, name)
+	f.impl.SetMetrics(registry, name)
+}
+
 // Open ...
 func (f *FileFlatDB) Open() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(false)
 
 	f.file.Open(f.file.path, false)
 	f.cache.lruBranch = make(LruMap)
 	f.cache.lruData = make(LruMap)
+	f.replayJournal()
 }
 
 // Close ...
 func (f *FileFlatDB) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(true)
 
 	f.file.Close()
@@ -57,26 +79,74 @@ func (f *FileFlatDB) Close() {
 
 // Drop ...
 func (f *FileFlatDB) Drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(false)
 	os.Remove(f.file.path)
+	os.Remove(f.journalPath())
 }
 
-// Empty ...
+// Empty ... A read-only database logs and ignores the call, TryEmpty returns
+// the error instead.
 func (f *FileFlatDB) Empty() {
+	if err := f.TryEmpty(); err != nil {
+		log.Printf("[fileflatdb] empty: %v", err)
+	}
+}
+
+// TryEmpty ...
+func (f *FileFlatDB) TryEmpty() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file.readOnly {
+		return db.ErrReadOnly
+	}
+
 	f.file.AssertOpen(false)
 	f.file.Open(f.file.path, true)
+	return nil
 }
 
-// Maintain ...
+// Maintain compacts the database, dropping values that have been replaced.
+// An open database stays usable while the entries are copied, a closed one is
+// opened for the duration of the compaction.
 func (f *FileFlatDB) Maintain(fn *db.ProgressCB) error {
-	f.file.AssertOpen(false)
-	compactor := NewCompact(f.file.file)
-	compactor.Maintain(fn)
-	return nil
+	f.mu.Lock()
+	isOpen := f.file.handle != nil
+	readOnly := f.file.readOnly
+	f.mu.Unlock()
+
+	if readOnly {
+		return db.ErrReadOnly
+	}
+
+	if !isOpen {
+		f.Open()
+		defer f.Close()
+	}
+
+	return NewCompact(f).Maintain(fn)
+}
+
+// MaintainAsync runs Maintain in the background, the returned channel
+// receives the result once the compacted file has been swapped in
+func (f *FileFlatDB) MaintainAsync(fn *db.ProgressCB) <-chan error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- f.Maintain(fn)
+	}()
+
+	return done
 }
 
 // Rename ...
 func (f *FileFlatDB) Rename(base, file string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(false)
 	oldPath := f.file.path
 
@@ -87,17 +157,41 @@ func (f *FileFlatDB) Rename(base, file string) {
 
 // Size ...
 func (f *FileFlatDB) Size() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file.readOnly && f.file.handle != nil {
+		f.file.Refresh()
+	}
+
 	return int(f.file.fileSize)
 }
 
-// Del ...
+// Del stores an empty value, which reads as a missing key. A read-only
+// database logs and drops the delete, TryDel returns the error instead.
 func (f *FileFlatDB) Del(key []uint8) {
-	log.Fatal("delete not implemented")
+	if err := f.TryDel(key); err != nil {
+		log.Printf("[fileflatdb] del: %v", err)
+	}
+}
+
+// TryDel ...
+func (f *FileFlatDB) TryDel(key []uint8) error {
+	return f.TryPut(key, []uint8{})
 }
 
 // Get ...
 func (f *FileFlatDB) Get(key []uint8) []uint8 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(true)
+	if f.file.readOnly {
+		f.file.Refresh()
+	}
+	if len(key) > f.serializer.MaxKeySize() {
+		return nil
+	}
 
 	k := f.FindKey(f.serializer.SerializeKey(key), false)
 	if k == nil {
@@ -107,15 +201,50 @@ func (f *FileFlatDB) Get(key []uint8) []uint8 {
 	result := f.ReadValue(k)
 
 	if result != nil && len(result.Value) > 0 {
-		return f.serializer.DeserializeValue(result.Value)
+		value := f.serializer.DeserializeValue(result.Value)
+		if len(value) == 0 {
+			// NOTE: deleted, see Del
+			return nil
+		}
+		if f.file.IsMapped() {
+			// NOTE: don't hand out slices of the mapping, it is released on close
+			value = append([]byte(nil), value...)
+		}
+
+		return value
 	}
 
 	return nil
 }
 
-// Put ...
+// Put ... A read-only database, or a key longer than the file stores, logs and
+// drops the write, TryPut returns the error instead.
 func (f *FileFlatDB) Put(key, value []uint8) {
+	if err := f.TryPut(key, value); err != nil {
+		log.Printf("[fileflatdb] put: %v", err)
+	}
+}
+
+// TryPut ...
+func (f *FileFlatDB) TryPut(key, value []uint8) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.file.AssertOpen(true)
+	if f.file.readOnly {
+		return db.ErrReadOnly
+	}
+	if len(key) > f.serializer.MaxKeySize() {
+		return ErrKeyTooLarge
+	}
+
+	f.put(key, value)
+	f.file.Commit()
+	return nil
+}
+
+// put writes the value, the caller holds the lock
+func (f *FileFlatDB) put(key, value []uint8) {
 	serializedKey := f.serializer.SerializeKey(key)
 	k := f.FindKey(serializedKey, true)
 	if k == nil {
@@ -124,11 +253,16 @@ func (f *FileFlatDB) Put(key, value []uint8) {
 
 	serializedValue := f.serializer.SerializeValue(value)
 	f.WriteValue(k, serializedValue)
+
+	if f.compactLog != nil {
+		key = f.serializer.DeserializeKey(serializedKey.Buffer)
+		f.compactLog[string(key)] = append([]byte(nil), serializedValue...)
+	}
 }
 
 // FindKey ...
 func (f *FileFlatDB) FindKey(key *NibbleBuffer, doCreate bool) *Key {
-	return f.impl.FindKey(key, doCreate, 0, 0)
+	return f.impl.FindKey(key, doCreate, 0, f.file.rootAt)
 }
 
 // ReadValue ...
@@ -1,17 +1,40 @@
 package fileflatdb
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/crypto"
+	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 )
 
 func TestFileFlatDB(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options *db.BaseDBOptions
+	}{
+		{"file reads", nil},
+		{"mmap reads", &db.BaseDBOptions{UseMmap: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testFileFlatDB(t, tt.options)
+		})
+	}
+}
+
+func testFileFlatDB(t *testing.T, options *db.BaseDBOptions) {
 	setUp()
 	defer cleanUp()
 
-	store := NewFileFlatDB(getLocation(), "store.db")
+	store := NewFileFlatDB(getLocation(), "store.db", options)
 	store.Open()
 
 	keyA := []byte{0x10, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
@@ -132,6 +155,769 @@ func TestFileFlatDB(t *testing.T) {
 	store.Close()
 }
 
+func TestFileFlatDBRemap(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	minSize := mmapMinSize
+	mmapMinSize = int64(branchSize)
+	defer func() {
+		mmapMinSize = minSize
+	}()
+
+	store := NewFileFlatDB(getLocation(), "store.db", &db.BaseDBOptions{UseMmap: true})
+	store.Open()
+
+	keys := benchKeys(256)
+	for i, key := range keys {
+		store.Put(key, []byte(strconv.Itoa(i)))
+	}
+
+	if len(store.file.retired) == 0 {
+		t.Error("expected the mapping to grow")
+	}
+
+	for i, key := range keys {
+		if !reflect.DeepEqual(store.Get(key), []byte(strconv.Itoa(i))) {
+			t.Errorf("value mismatch for key %d", i)
+		}
+	}
+
+	store.Close()
+	store.Open()
+	defer store.Close()
+
+	for i, key := range keys {
+		if !reflect.DeepEqual(store.Get(key), []byte(strconv.Itoa(i))) {
+			t.Errorf("value mismatch for key %d after reopening", i)
+		}
+	}
+}
+
+func TestFileFlatDBMaintain(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options *db.BaseDBOptions
+	}{
+		{"file reads", nil},
+		{"mmap reads", &db.BaseDBOptions{UseMmap: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setUp()
+			defer cleanUp()
+
+			store := NewFileFlatDB(getLocation(), "store.db", tt.options)
+			store.Open()
+
+			keys := benchKeys(64)
+			for i, key := range keys {
+				store.Put(key, []byte(strconv.Itoa(i)))
+			}
+			// NOTE: growing values are appended, leaving the old ones behind
+			for i, key := range keys {
+				store.Put(key, []byte(fmt.Sprintf("a much larger value for %d", i)))
+			}
+
+			sizeBefore := store.Size()
+			if err := store.Maintain(nil); err != nil {
+				t.Fatal(err)
+			}
+			if store.Size() >= sizeBefore {
+				t.Errorf("expected size to shrink from %d, got %d", sizeBefore, store.Size())
+			}
+
+			during := benchKeys(80)[64:]
+			var written int
+			var completed bool
+			var progress db.ProgressCB = func(value *db.ProgressValue) {
+				if value.IsCompleted {
+					completed = true
+					return
+				}
+				// NOTE: write while the entries are being copied
+				if written < len(during) {
+					store.Put(during[written], []byte("written during compaction"))
+					store.Put(keys[written], []byte("updated"))
+					written++
+				}
+			}
+
+			if err := <-store.MaintainAsync(&progress); err != nil {
+				t.Fatal(err)
+			}
+			if !completed {
+				t.Error("expected completed progress")
+			}
+			if written == 0 {
+				t.Error("expected writes during compaction")
+			}
+
+			check := func() {
+				for i, key := range keys {
+					want := []byte(fmt.Sprintf("a much larger value for %d", i))
+					if i < written {
+						want = []byte("updated")
+					}
+					if !reflect.DeepEqual(store.Get(key), want) {
+						t.Errorf("value mismatch for key %d", i)
+					}
+				}
+				for i := 0; i < written; i++ {
+					if !reflect.DeepEqual(store.Get(during[i]), []byte("written during compaction")) {
+						t.Errorf("value mismatch for key written during compaction %d", i)
+					}
+				}
+			}
+
+			check()
+			store.Close()
+
+			if err := store.Maintain(nil); err != nil {
+				t.Fatal(err)
+			}
+
+			store.Open()
+			defer store.Close()
+			check()
+		})
+	}
+}
+
+func TestFileFlatDBCompression(t *testing.T) {
+	compressible := []byte(strings.Repeat("compressible ", 32))
+	small := []byte("small")
+
+	for _, tt := range []struct {
+		name        string
+		options     *db.BaseDBOptions
+		compression db.Compression
+	}{
+		{"none", nil, db.CompressionNone},
+		{"snappy", &db.BaseDBOptions{Compression: db.CompressionSnappy}, db.CompressionSnappy},
+		{"snappy (IsCompressed)", &db.BaseDBOptions{IsCompressed: true}, db.CompressionSnappy},
+		{"zstd", &db.BaseDBOptions{Compression: db.CompressionZstd}, db.CompressionZstd},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setUp()
+			defer cleanUp()
+
+			store := NewFileFlatDB(getLocation(), "store.db", tt.options)
+			store.Open()
+
+			keys := benchKeys(16)
+			for _, key := range keys {
+				store.Put(key, compressible)
+			}
+			store.Put([]byte{0x01}, small)
+
+			raw := store.file.fileSize
+			if tt.compression != db.CompressionNone && raw >= int64(16*len(compressible)) {
+				t.Errorf("expected compressed values, file is %d bytes", raw)
+			}
+
+			k := store.FindKey(store.serializer.SerializeKey([]byte{0x01}), false)
+			if stored := store.impl.ReadValue(k.KeyValue).Value; stored[0] != valueRaw {
+				t.Errorf("expected small values to be stored raw, got flag %d", stored[0])
+			}
+			store.Close()
+
+			// NOTE: the codec is read from the header, not the options
+			store = NewFileFlatDB(getLocation(), "store.db", nil)
+			store.Open()
+			defer store.Close()
+
+			if store.file.compression != tt.compression {
+				t.Errorf("expected compression %d, got %d", tt.compression, store.file.compression)
+			}
+			for _, key := range keys {
+				if !reflect.DeepEqual(store.Get(key), compressible) {
+					t.Error("value mismatch after reopening")
+				}
+			}
+			if !reflect.DeepEqual(store.Get([]byte{0x01}), small) {
+				t.Error("small value mismatch after reopening")
+			}
+		})
+	}
+}
+
+func TestFileFlatDBLegacy(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	// a headerless file, as written before the format was versioned
+	if err := ioutil.WriteFile(fmt.Sprintf("%s/store.db", getLocation()), make([]byte, branchSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &db.BaseDBOptions{IsCompressed: true}
+	store := NewFileFlatDB(getLocation(), "store.db", options)
+	store.Open()
+
+	if store.file.version != 0 || !store.serializer.IsLegacy {
+		t.Fatal("expected a legacy file")
+	}
+
+	keys := benchKeys(64)
+	for i, key := range keys {
+		store.Put(key, []byte(strings.Repeat(strconv.Itoa(i), 64)))
+	}
+
+	if err := store.Maintain(nil); err != nil {
+		t.Fatal(err)
+	}
+	if store.file.version != formatVersion || store.file.compression != db.CompressionSnappy {
+		t.Errorf("expected migration to version %d with snappy", formatVersion)
+	}
+	store.Close()
+
+	store = NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	for i, key := range keys {
+		if !reflect.DeepEqual(store.Get(key), []byte(strings.Repeat(strconv.Itoa(i), 64))) {
+			t.Errorf("value mismatch for key %d after migration", i)
+		}
+	}
+}
+
+func TestFileFlatDBLegacyKeys(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	// a headerless file, keys walked with the nibbles of the unpadded key
+	if err := ioutil.WriteFile(fmt.Sprintf("%s/store.db", getLocation()), make([]byte, branchSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+
+	if !store.serializer.IsLegacy {
+		t.Fatal("expected the legacy key layout")
+	}
+
+	keys := benchKeys(64)
+	for i, key := range keys {
+		keys[i] = key[:20]
+		store.Put(keys[i], []byte(strconv.Itoa(i)))
+	}
+	// NOTE: matched on the first 16 bytes, this lands in the leaf of keys[0]
+	shared := append(append([]byte(nil), keys[0][:16]...), 0xff, 0xff, 0xff, 0xff)
+	store.Put(shared, []byte("shared"))
+	store.Close()
+
+	store.Open()
+	if !reflect.DeepEqual(store.Get(shared), []byte("shared")) {
+		t.Error("expected the entry of a headerless file to be found")
+	}
+	store.Close()
+	keys = keys[1:]
+
+	check := func(size int) {
+		for i, key := range keys {
+			key = append(append([]byte(nil), key...), make([]byte, size-len(key))...)
+			if !reflect.DeepEqual(store.Get(key), []byte(strconv.Itoa(i+1))) {
+				t.Errorf("value mismatch for key %d", i)
+			}
+		}
+	}
+
+	store.Open()
+	check(20)
+
+	if err := store.Maintain(nil); err != nil {
+		t.Fatal(err)
+	}
+	if store.file.version != formatVersion || store.serializer.IsLegacy {
+		t.Errorf("expected migration to version %d", formatVersion)
+	}
+	// NOTE: the file only kept the padded keys, they are migrated as such
+	check(keySize)
+	store.Close()
+}
+
+func TestFileFlatDBMaintainDeleted(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	keys := benchKeys(64)
+	for i, key := range keys {
+		store.Put(key, []byte(strconv.Itoa(i)))
+	}
+	for _, key := range keys[32:] {
+		store.Del(key)
+	}
+
+	var copied int
+	var progress db.ProgressCB = func(value *db.ProgressValue) {
+		if value.IsCompleted {
+			copied = value.Keys
+		}
+	}
+	if err := store.Maintain(&progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if copied != 32 {
+		t.Errorf("expected the deleted keys to be dropped, copied %d", copied)
+	}
+	for i, key := range keys {
+		var want []byte
+		if i < 32 {
+			want = []byte(strconv.Itoa(i))
+		}
+		if !reflect.DeepEqual(store.Get(key), want) {
+			t.Errorf("value mismatch for key %d", i)
+		}
+	}
+}
+
+func TestFileFlatDBIterate(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	keys := benchKeys(128)
+	values := map[string][]byte{}
+	for i, key := range keys {
+		values[string(key)] = []byte(strconv.Itoa(i))
+		store.Put(key, values[string(key)])
+	}
+
+	t.Run("iterates all keys in order", func(t *testing.T) {
+		var prev []byte
+		count := 0
+		store.Iterate(nil, func(key, value []byte) bool {
+			if prev != nil && bytes.Compare(prev, key) >= 0 {
+				t.Errorf("keys out of order, %x after %x", key, prev)
+			}
+			if !reflect.DeepEqual(value, values[string(key)]) {
+				t.Errorf("value mismatch for %x", key)
+			}
+			prev = key
+			count++
+			return true
+		})
+
+		if count != len(keys) {
+			t.Errorf("expected %d keys, got %d", len(keys), count)
+		}
+	})
+
+	t.Run("iterates a prefix", func(t *testing.T) {
+		prefix := keys[0][:1]
+		expected := 0
+		for _, key := range keys {
+			if bytes.HasPrefix(key, prefix) {
+				expected++
+			}
+		}
+
+		count := 0
+		store.Iterate(prefix, func(key, value []byte) bool {
+			if !bytes.HasPrefix(key, prefix) {
+				t.Errorf("unexpected key %x", key)
+			}
+			count++
+			return true
+		})
+
+		if count != expected {
+			t.Errorf("expected %d keys, got %d", expected, count)
+		}
+	})
+
+	t.Run("restarts when compacted while iterating", func(t *testing.T) {
+		var prev []byte
+		count := 0
+		store.Iterate(nil, func(key, value []byte) bool {
+			if count == len(keys)/2 {
+				// NOTE: rewrites the file under the walk
+				for _, key := range keys {
+					store.Put(key, values[string(key)])
+				}
+				if err := store.Maintain(nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if prev != nil && bytes.Compare(prev, key) >= 0 {
+				t.Errorf("keys out of order, %x after %x", key, prev)
+			}
+			if !reflect.DeepEqual(value, values[string(key)]) {
+				t.Errorf("value mismatch for %x", key)
+			}
+			prev = key
+			count++
+			return true
+		})
+
+		if count != len(keys) {
+			t.Errorf("expected %d keys, got %d", len(keys), count)
+		}
+	})
+
+	t.Run("iterates while compacting", func(t *testing.T) {
+		done := store.MaintainAsync(nil)
+		for running := true; running; {
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+				running = false
+			default:
+			}
+
+			count := 0
+			store.Iterate(nil, func(key, value []byte) bool {
+				if !reflect.DeepEqual(value, values[string(key)]) {
+					t.Errorf("value mismatch for %x", key)
+				}
+				count++
+				return true
+			})
+			if count != len(keys) {
+				t.Fatalf("expected %d keys, got %d", len(keys), count)
+			}
+		}
+	})
+
+	t.Run("returns keys as written", func(t *testing.T) {
+		short := [][]byte{{0xff, 0xfe}, {0xff, 0xfe, 0x00}, {0xff, 0xfe, 0x00, 0x01}}
+		for i, key := range short {
+			store.Put(key, []byte(strconv.Itoa(i)))
+		}
+
+		var found [][]byte
+		store.Iterate([]byte{0xff, 0xfe}, func(key, value []byte) bool {
+			found = append(found, key)
+			return true
+		})
+
+		if !reflect.DeepEqual(found, short) {
+			t.Errorf("unexpected keys %x", found)
+		}
+		for i, key := range short {
+			if !reflect.DeepEqual(store.Get(key), []byte(strconv.Itoa(i))) {
+				t.Errorf("value mismatch for %x", key)
+			}
+		}
+	})
+}
+
+func TestFileFlatDBKeySize(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	long := append(benchKeys(1)[0], benchKeys(2)[1]...)
+	if err := store.TryPut(long, []byte("long")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.Get(long), []byte("long")) {
+		t.Error("expected keys of up to 64 bytes to be stored")
+	}
+
+	tooLong := append(long, 0x01)
+	if err := store.TryPut(tooLong, []byte("value")); err != ErrKeyTooLarge {
+		t.Errorf("expected ErrKeyTooLarge, got %v", err)
+	}
+	batch := store.NewBatch()
+	batch.Put(tooLong, []byte("value"))
+	if err := batch.Write(); err != ErrKeyTooLarge {
+		t.Errorf("expected ErrKeyTooLarge on batch, got %v", err)
+	}
+	if store.Get(tooLong) != nil {
+		t.Error("expected no value for a key that is too large")
+	}
+}
+
+func TestFileFlatDBNamespaced(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	headers := db.Namespaced(store, []byte("header/"))
+	bodies := db.Namespaced(store, []byte("body/"))
+
+	// NOTE: hashes, the keys stored are prefixed and longer than 32 bytes
+	hashes := benchKeys(3)
+	for i, hash := range hashes {
+		headers.Put(hash, []byte("header "+strconv.Itoa(i)))
+	}
+	bodies.Put(hashes[0], []byte("body"))
+
+	for i, hash := range hashes {
+		if !reflect.DeepEqual(headers.Get(hash), []byte("header "+strconv.Itoa(i))) {
+			t.Errorf("header mismatch for %x", hash)
+		}
+	}
+	if !reflect.DeepEqual(bodies.Get(hashes[0]), []byte("body")) {
+		t.Error("body mismatch")
+	}
+	if bodies.Get(hashes[1]) != nil {
+		t.Error("expected the columns to be separate")
+	}
+
+	count := 0
+	headers.Iterate(nil, func(key, value []byte) bool {
+		if len(key) != keySize {
+			t.Errorf("expected the prefix to be removed, got %x", key)
+		}
+		count++
+		return true
+	})
+	if count != len(hashes) {
+		t.Errorf("expected %d headers, got %d", len(hashes), count)
+	}
+
+	batch := headers.NewBatch()
+	batch.Put(append(hashes[0], hashes[1]...), []byte("value"))
+	if err := batch.Write(); err != ErrKeyTooLarge {
+		t.Errorf("expected ErrKeyTooLarge, got %v", err)
+	}
+}
+
+func TestFileFlatDBBatch(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+
+	keys := benchKeys(3)
+	store.Put(keys[0], []byte("deleted"))
+
+	t.Run("writes the batch", func(t *testing.T) {
+		batch := store.NewBatch()
+		batch.Put(keys[1], []byte("one"))
+		batch.Del(keys[0])
+
+		if store.Get(keys[1]) != nil {
+			t.Error("expected the batch to be pending until written")
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if store.Get(keys[0]) != nil {
+			t.Error("expected the key to be deleted")
+		}
+		if !reflect.DeepEqual(store.Get(keys[1]), []byte("one")) {
+			t.Error("expected the key to be written")
+		}
+	})
+
+	t.Run("completes an interrupted batch on open", func(t *testing.T) {
+		var buf bytes.Buffer
+		ops := []*db.KV{{Key: keys[2], Value: []byte("two")}, {Key: keys[1], Value: nil}}
+		if err := gob.NewEncoder(&buf).Encode(ops); err != nil {
+			t.Fatal(err)
+		}
+
+		store.Close()
+		// NOTE: a committed batch, interrupted before any of it was applied
+		if err := writeFileSync(store.journalPath(), buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		store.Open()
+		defer store.Close()
+
+		if store.Get(keys[1]) != nil {
+			t.Error("expected the key to be deleted")
+		}
+		if !reflect.DeepEqual(store.Get(keys[2]), []byte("two")) {
+			t.Error("expected the journaled value to be written")
+		}
+		if _, err := os.Stat(store.journalPath()); !os.IsNotExist(err) {
+			t.Error("expected the journal to be removed")
+		}
+	})
+}
+
+func TestFileFlatDBArchive(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.Open()
+	defer store.Close()
+
+	keys := benchKeys(64)
+	for i, key := range keys {
+		store.Put(key, []byte(strconv.Itoa(i)))
+	}
+
+	// FileFlatDB -> MemoryDB -> FileTreeDB
+	memoryDB := db.NewMemoryDB(nil)
+	fileTreeDB := db.NewFileTreeDBDB(fmt.Sprintf("%s/tree", getLocation()))
+
+	var archive bytes.Buffer
+	if err := db.Export(store, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Import(memoryDB, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Reset()
+	if err := db.Export(memoryDB, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Import(fileTreeDB, &archive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range keys {
+		if !reflect.DeepEqual(fileTreeDB.Get(key), []byte(strconv.Itoa(i))) {
+			t.Errorf("value mismatch for key %d", i)
+		}
+	}
+}
+
+func TestFileFlatDBReadOnly(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options *db.BaseDBOptions
+	}{
+		{"file reads", &db.BaseDBOptions{ReadOnly: true}},
+		{"mmap reads", &db.BaseDBOptions{ReadOnly: true, UseMmap: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setUp()
+			defer cleanUp()
+
+			writer := NewFileFlatDB(getLocation(), "store.db", nil)
+			writer.Open()
+			defer writer.Close()
+
+			keys := benchKeys(64)
+			writer.Put(keys[0], []byte("before"))
+
+			reader := NewFileFlatDB(getLocation(), "store.db", tt.options)
+			reader.Open()
+			defer reader.Close()
+
+			if !reflect.DeepEqual(reader.Get(keys[0]), []byte("before")) {
+				t.Error("expected the reader to see existing values")
+			}
+
+			if err := reader.TryPut(keys[1], []byte("value")); err != db.ErrReadOnly {
+				t.Errorf("expected ErrReadOnly on put, got %v", err)
+			}
+			if err := reader.TryDel(keys[0]); err != db.ErrReadOnly {
+				t.Errorf("expected ErrReadOnly on del, got %v", err)
+			}
+			if err := reader.TryEmpty(); err != db.ErrReadOnly {
+				t.Errorf("expected ErrReadOnly on empty, got %v", err)
+			}
+			if err := reader.NewBatch().Write(); err != db.ErrReadOnly {
+				t.Errorf("expected ErrReadOnly on batch, got %v", err)
+			}
+
+			// NOTE: the unchecked writes are dropped, not fatal
+			reader.Put(keys[1], []byte("value"))
+			reader.Del(keys[0])
+			reader.Empty()
+			if reader.Get(keys[1]) != nil || !reflect.DeepEqual(reader.Get(keys[0]), []byte("before")) {
+				t.Error("expected the unchecked writes to be dropped")
+			}
+
+			// NOTE: appended and updated in place after the reader opened
+			for i, key := range keys {
+				writer.Put(key, []byte(strconv.Itoa(i)))
+			}
+			for i, key := range keys {
+				if !reflect.DeepEqual(reader.Get(key), []byte(strconv.Itoa(i))) {
+					t.Errorf("expected the reader to see the value written for key %d", i)
+				}
+			}
+			if reader.Size() != writer.Size() {
+				t.Errorf("expected size %d, got %d", writer.Size(), reader.Size())
+			}
+
+			if err := writer.Maintain(nil); err != nil {
+				t.Fatal(err)
+			}
+			writer.Put(keys[0], []byte("after compaction"))
+			if !reflect.DeepEqual(reader.Get(keys[0]), []byte("after compaction")) {
+				t.Error("expected the reader to follow the compacted file")
+			}
+		})
+	}
+}
+
+func TestFileFlatDBLock(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	writer := NewFileFlatDB(getLocation(), "store.db", nil)
+	writer.Open()
+
+	other := NewFile(getLocation(), "store.db", nil)
+	if err := other.tryLock(other.path); err == nil {
+		t.Error("expected a second writer to be refused")
+	}
+
+	if err := writer.Maintain(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.tryLock(other.path); err == nil {
+		t.Error("expected the lock to be kept across compaction")
+	}
+
+	writer.Close()
+
+	if err := other.tryLock(other.path); err != nil {
+		t.Errorf("expected the lock to be released on close, got %v", err)
+	}
+	other.unlock()
+}
+
+func TestFileFlatDBMetrics(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	registry := metrics.NewRegistry()
+	store := NewFileFlatDB(getLocation(), "store.db", nil)
+	store.SetMetrics(registry, "state")
+	store.Open()
+	defer store.Close()
+
+	keys := benchKeys(16)
+	for _, key := range keys {
+		store.Put(key, []byte("value"))
+	}
+	for _, key := range keys {
+		store.Get(key)
+	}
+
+	appended := registry.Counter("state_bytes_appended").Value()
+	if appended != store.file.fileSize-int64(headerSize+branchSize) {
+		t.Errorf("expected the appended bytes to match the file growth, got %d", appended)
+	}
+	if registry.Counter("state_bytes_written").Value() <= appended {
+		t.Error("expected in place writes to be counted")
+	}
+	if registry.Counter("state_branch_hits").Value() == 0 {
+		t.Error("expected branch cache hits")
+	}
+}
+
 func setUp() {
 	testpath := getLocation()
 	if _, err := os.Stat(testpath); os.IsNotExist(err) {
@@ -160,3 +946,77 @@ func getLocation() string {
 
 	return fmt.Sprintf("%s/test", dirpath)
 }
+
+func benchKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		hash := crypto.NewBlake2b256([]byte(strconv.Itoa(i)))
+		keys[i] = hash[:]
+	}
+
+	return keys
+}
+
+func BenchmarkFileFlatDBPut(b *testing.B) {
+	for _, bb := range []struct {
+		name    string
+		options *db.BaseDBOptions
+	}{
+		{"sync never", &db.BaseDBOptions{Sync: db.SyncNever}},
+		{"sync periodic", &db.BaseDBOptions{Sync: db.SyncPeriodic}},
+		{"sync every commit", &db.BaseDBOptions{Sync: db.SyncEveryCommit}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			setUp()
+			defer cleanUp()
+
+			store := NewFileFlatDB(getLocation(), "bench.db", bb.options)
+			store.Open()
+			defer store.Close()
+
+			keys := benchKeys(b.N)
+			value := []byte("benchmark value")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.Put(keys[i], value)
+			}
+		})
+	}
+}
+
+func BenchmarkFileFlatDBGet(b *testing.B) {
+	for _, bb := range []struct {
+		name    string
+		options *db.BaseDBOptions
+	}{
+		{"file reads", nil},
+		{"mmap reads", &db.BaseDBOptions{UseMmap: true}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			setUp()
+			defer cleanUp()
+
+			store := NewFileFlatDB(getLocation(), "bench.db", bb.options)
+			store.Open()
+			defer store.Close()
+
+			keys := benchKeys(1024)
+			value := []byte("benchmark value")
+			for _, key := range keys {
+				store.Put(key, value)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// NOTE: drop the caches so reads hit the file
+				if i%len(keys) == 0 {
+					store.cache.lruBranch = make(LruMap)
+					store.cache.lruData = make(LruMap)
+				}
+
+				store.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}
@@ -3,7 +3,8 @@ package fileflatdb
 import (
 	"log"
 	"math/big"
-	"os"
+
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 )
 
 // Key ...
@@ -27,7 +28,9 @@ type ValueInfo struct {
 
 // Impl ...
 type Impl struct {
-	cache *Cache
+	cache         *Cache
+	bytesWritten  *metrics.Counter
+	bytesAppended *metrics.Counter
 }
 
 // NewImpl ...
@@ -37,9 +40,16 @@ func NewImpl(cache *Cache) *Impl {
 	}
 }
 
+// SetMetrics registers the bytes written to the file, and the part of them
+// appended to it, as <name>_<metric>
+func (i *Impl) SetMetrics(registry *metrics.Registry, name string) {
+	i.bytesWritten = registry.Counter(name + "_bytes_written")
+	i.bytesAppended = registry.Counter(name + "_bytes_appended")
+}
+
 // GetKeyValue ...
 func (i *Impl) GetKeyValue(keyAt int64) []byte {
-	return i.cache.GetCachedData(keyAt, int64(keyTotalSize))
+	return i.cache.GetCachedData(keyAt, int64(i.cache.file.serializer.storedKeySize()+2*uintSize))
 }
 
 // RetrieveBranch ...
@@ -66,10 +76,15 @@ func (i *Impl) RetrieveLeaf(doCreate bool, branch []byte, branchAt int, entryInd
 	keyAt.SetBytes(branch[entryIndex+1 : entryIndex+1+uintSize])
 
 	keyValue := i.GetKeyValue(int64(keyAt.Uint64()))
-	prevKey := i.cache.file.serializer.SerializeKey(keyValue[0:keySize])
+	serializer := i.cache.file.serializer
+	prevKey := serializer.SerializeKey(serializer.DeserializeKey(keyValue))
 	matchIndex := keyIndex
+	matchSize := len(prevKey.Nibbles)
+	if serializer.IsLegacy {
+		matchSize = keySize
+	}
 
-	for matchIndex < keySize {
+	for matchIndex < matchSize {
 		if matchIndex >= len(prevKey.Nibbles) || matchIndex >= len(key.Nibbles) {
 			break
 		}
@@ -80,7 +95,7 @@ func (i *Impl) RetrieveLeaf(doCreate bool, branch []byte, branchAt int, entryInd
 		matchIndex++
 	}
 
-	if matchIndex != keySize {
+	if matchIndex != matchSize {
 		if doCreate {
 			return i.WriteNewBranch(branch, int64(branchAt), int64(entryIndex), key, int64(keyAt.Uint64()), prevKey, uint64(matchIndex), int64(matchIndex-keyIndex-1))
 		}
@@ -118,11 +133,13 @@ func (i *Impl) FindKey(key *NibbleBuffer, doCreate bool, keyIndex, branchAt int6
 
 // ExtractValueInfo ...
 func (i *Impl) ExtractValueInfo(keyValue []byte) *ValueInfo {
+	storedKeySize := i.cache.file.serializer.storedKeySize()
+
 	valueLength := new(big.Int)
-	valueLength.SetBytes(keyValue[keySize : keySize+uintSize])
+	valueLength.SetBytes(keyValue[storedKeySize : storedKeySize+uintSize])
 
 	valueAt := new(big.Int)
-	valueAt.SetBytes(keyValue[keySize+uintSize : keySize+uintSize+uintSize])
+	valueAt.SetBytes(keyValue[storedKeySize+uintSize : storedKeySize+uintSize+uintSize])
 
 	return &ValueInfo{
 		ValueLength: int64(valueLength.Uint64()),
@@ -150,13 +167,13 @@ func (i *Impl) WriteValue(keyAt int, keyValue []byte, value []byte) *Value {
 		valueAt = int64(i.WriteUpdatedBuffer(value, current.ValueAt))
 	}
 
-	writeUIntBE(keyValue, int64(len(value)), int64(keySize), int64(uintSize))
-	writeUIntBE(keyValue, int64(valueAt), int64(keySize)+int64(uintSize), int64(uintSize))
-	file := i.openFile()
-	defer file.Close()
-	if _, err := file.WriteAt(keyValue[keySize:keySize+2*uintSize], int64(keyAt)+int64(keySize)); err != nil {
+	storedKeySize := i.cache.file.serializer.storedKeySize()
+	writeUIntBE(keyValue, int64(len(value)), int64(storedKeySize), int64(uintSize))
+	writeUIntBE(keyValue, int64(valueAt), int64(storedKeySize)+int64(uintSize), int64(uintSize))
+	if err := i.cache.file.WriteAt(keyValue[storedKeySize:storedKeySize+2*uintSize], int64(keyAt)+int64(storedKeySize)); err != nil {
 		log.Fatalf("[fileflatdb] failed to write value: %v\n", err)
 	}
+	i.bytesWritten.Add(int64(2 * uintSize))
 
 	return &Value{
 		Value:   value,
@@ -166,10 +183,8 @@ func (i *Impl) WriteValue(keyAt int, keyValue []byte, value []byte) *Value {
 
 // WriteNewKey ...
 func (i *Impl) WriteNewKey(key *NibbleBuffer) *Key {
-	keyValue := make([]byte, keyTotalSize)
-	for i, b := range key.Buffer {
-		keyValue[i] = b
-	}
+	keyValue := make([]byte, i.cache.file.serializer.storedKeySize()+2*uintSize)
+	copy(keyValue, key.Buffer)
 
 	keyAt := i.WriteNewBuffer(keyValue, true)
 
@@ -232,11 +247,10 @@ func (i *Impl) WriteNewBranch(branch []byte, branchAt int64, entryIndex int64, k
 
 	writeUIntBE(branch, int64(newBranchAt), int64(entryIndex)+1, int64(uintSize))
 
-	file := i.openFile()
-	defer file.Close()
-	if _, err := file.WriteAt(branch[entryIndex:entryIndex+int64(entrySize)], int64(branchAt)+int64(entryIndex)); err != nil {
+	if err := i.cache.file.WriteAt(branch[entryIndex:entryIndex+int64(entrySize)], int64(branchAt)+int64(entryIndex)); err != nil {
 		log.Fatalf("[fileflatdb] failed to write branch: %v\n", err)
 	}
+	i.bytesWritten.Add(int64(entrySize))
 
 	return &Key{
 		Key:      key,
@@ -251,11 +265,10 @@ func (i *Impl) WriteNewLeaf(branch []byte, branchAt int64, entryIndex int64, key
 
 	branch[entryIndex] = byte(SlotLeaf)
 	writeUIntBE(branch, int64(newKey.KeyAt), int64(entryIndex)+1, int64(uintSize))
-	file := i.openFile()
-	defer file.Close()
-	if _, err := file.WriteAt(branch[entryIndex:entryIndex+int64(entrySize)], int64(branchAt)+int64(entryIndex)); err != nil {
+	if err := i.cache.file.WriteAt(branch[entryIndex:entryIndex+int64(entrySize)], int64(branchAt)+int64(entryIndex)); err != nil {
 		log.Fatalf("[fileflatdb] failed to write leaf: %v\n", err)
 	}
+	i.bytesWritten.Add(int64(entrySize))
 
 	return &Key{
 		Key:      key,
@@ -267,12 +280,12 @@ func (i *Impl) WriteNewLeaf(branch []byte, branchAt int64, entryIndex int64, key
 // WriteUpdatedBuffer  ...
 func (i *Impl) WriteUpdatedBuffer(buffer []byte, bufferAt int64) int64 {
 
-	file := i.openFile()
-	defer file.Close()
-	if _, err := file.WriteAt(buffer, bufferAt); err != nil {
+	if err := i.cache.file.WriteAt(buffer, bufferAt); err != nil {
 		log.Fatal(err)
 	}
 
+	i.bytesWritten.Add(int64(len(buffer)))
+
 	i.cache.CacheData(bufferAt, buffer)
 	return bufferAt
 }
@@ -281,12 +294,13 @@ func (i *Impl) WriteUpdatedBuffer(buffer []byte, bufferAt int64) int64 {
 func (i *Impl) WriteNewBuffer(buffer []byte, withCache bool) int64 {
 	startAt := i.cache.file.fileSize
 
-	file := i.openFile()
-	defer file.Close()
-	if _, err := file.WriteAt(buffer, startAt); err != nil {
+	if err := i.cache.file.WriteAt(buffer, startAt); err != nil {
 		log.Fatalf("[fileflatdb] error writing new buffer to file: %v\n", err)
 	}
 
+	i.bytesWritten.Add(int64(len(buffer)))
+	i.bytesAppended.Add(int64(len(buffer)))
+
 	if withCache {
 		i.cache.CacheData(startAt, buffer)
 	}
@@ -312,24 +326,3 @@ func (i *Impl) WriteNewBuffers(buffers [][]byte) int64 {
 
 	return i.WriteNewBuffer(concenatedBuffers, false)
 }
-
-// openFile ...
-func (i *Impl) openFile() *os.File {
-	filepath := i.cache.file.path
-
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		log.Fatalf("[fileflatdb] file %q does not exist", filepath)
-	}
-
-	file, err := os.OpenFile(filepath, os.O_RDWR, 0755)
-	if err != nil {
-		log.Fatalf("[fileflatdb] error opening file: %v", err)
-	}
-
-	return file
-}
-
-// fd ...
-func (i *Impl) fd() uintptr {
-	return i.cache.file.fd
-}
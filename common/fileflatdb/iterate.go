@@ -0,0 +1,169 @@
+package fileflatdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+var errFileSwapped = errors.New("[fileflatdb/iterate] file swapped while iterating")
+
+// Iterate walks the branch tree in nibble order, calling fn with every key
+// starting with prefix. Keys are returned as written, headerless files only
+// store them padded to keySize. The lock is only held while reading, so fn
+// may access the database. When the file is swapped meanwhile, by a
+// compaction or by a read-only handle picking up the compacted file, the walk
+// restarts from the new root and skips the keys already returned.
+func (f *FileFlatDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	it := &iterator{
+		db:     f,
+		prefix: prefix,
+		fn:     fn,
+	}
+
+	for {
+		rootAt, ok := it.start()
+		if !ok {
+			return
+		}
+
+		_, err := it.iterateBranch(rootAt, 0)
+		if err != errFileSwapped {
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			return
+		}
+	}
+}
+
+// iterator ...
+type iterator struct {
+	db         *FileFlatDB
+	prefix     []uint8
+	nibbles    []uint8
+	fn         func(key, value []uint8) bool
+	generation uint64
+	// NOTE: the last key passed to fn, keys up to it are skipped on a restart
+	last []byte
+}
+
+// start returns the root of the file, recording the file it belongs to. No
+// key matches a prefix longer than the file stores.
+func (it *iterator) start() (int64, bool) {
+	f := it.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.file.AssertOpen(true)
+	if f.file.readOnly {
+		f.file.Refresh()
+	}
+
+	it.generation = f.file.generation
+	if len(it.prefix) > f.serializer.MaxKeySize() {
+		return 0, false
+	}
+	// NOTE: the key layout may have changed with the file
+	it.nibbles = f.serializer.SerializeKey(it.prefix).Nibbles[:len(it.prefix)*2]
+
+	return f.file.rootAt, true
+}
+
+// iterateBranch visits the entries of the branch at branchAt, returning false
+// once fn stopped the iteration
+func (it *iterator) iterateBranch(branchAt int64, depth int) (bool, error) {
+	branch, err := it.readBranch(branchAt)
+	if err != nil {
+		return false, err
+	}
+
+	from, to := 0, entryNum
+	// NOTE: while within the prefix only a single entry can match
+	if depth < len(it.nibbles) {
+		from, to = int(it.nibbles[depth]), int(it.nibbles[depth])+1
+	}
+
+	for index := from; index < to; index++ {
+		entry := branch[index*entrySize : (index+1)*entrySize]
+		pointer := new(big.Int)
+		pointer.SetBytes(entry[1 : 1+uintSize])
+
+		switch int(entry[0]) {
+		case SlotEmpty:
+		case SlotLeaf:
+			key, value, err := it.readLeaf(int64(pointer.Uint64()))
+			if err != nil {
+				return false, err
+			}
+			if len(value) == 0 || !bytes.HasPrefix(key, it.prefix) {
+				continue
+			}
+			if it.last != nil && bytes.Compare(key, it.last) <= 0 {
+				continue
+			}
+
+			it.last = key
+			if !it.fn(key, value) {
+				return false, nil
+			}
+		case SlotBranch:
+			more, err := it.iterateBranch(int64(pointer.Uint64()), depth+1)
+			if err != nil || !more {
+				return false, err
+			}
+		default:
+			return false, fmt.Errorf("[fileflatdb/iterate] unknown entry type %d", entry[0])
+		}
+	}
+
+	return true, nil
+}
+
+// readBranch returns a copy of the branch at branchAt
+func (it *iterator) readBranch(branchAt int64) ([]byte, error) {
+	f := it.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := it.check(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), f.cache.GetCachedBranch(branchAt)...), nil
+}
+
+// readLeaf returns copies of the key and the deserialized value at keyAt
+func (it *iterator) readLeaf(keyAt int64) ([]byte, []byte, error) {
+	f := it.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := it.check(); err != nil {
+		return nil, nil, err
+	}
+
+	keyValue := f.impl.GetKeyValue(keyAt)
+	value := f.impl.ReadValue(keyValue).Value
+	if len(value) > 0 {
+		value = f.serializer.DeserializeValue(value)
+	}
+
+	return append([]byte(nil), f.serializer.DeserializeKey(keyValue)...), append([]byte(nil), value...), nil
+}
+
+// check fails once the file the walk started on has been replaced, the
+// caller holds the lock
+func (it *iterator) check() error {
+	f := it.db
+	f.file.AssertOpen(true)
+
+	if f.file.generation != it.generation {
+		return errFileSwapped
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package fileflatdb
+
+// flock is only available on unix platforms, elsewhere writers are not
+// protected from each other
+func (f *File) flock() error {
+	return nil
+}
+
+// funlock ...
+func (f *File) funlock() error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package fileflatdb
+
+import (
+	"syscall"
+)
+
+// flock takes an exclusive lock on the open lock file without blocking
+func (f *File) flock() error {
+	return syscall.Flock(int(f.lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// funlock releases the lock taken by flock
+func (f *File) funlock() error {
+	return syscall.Flock(int(f.lock.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+package fileflatdb
+
+import (
+	"syscall"
+)
+
+var mmapSupported = true
+
+// mmap maps length bytes of the open file into memory. The mapping is shared
+// and writable since Impl updates branch and key entries in place before
+// writing them out, read-only files are mapped read-only.
+func (f *File) mmap(length int64) ([]byte, error) {
+	prot := syscall.PROT_READ | syscall.PROT_WRITE
+	if f.readOnly {
+		prot = syscall.PROT_READ
+	}
+
+	return syscall.Mmap(int(f.handle.Fd()), 0, int(length), prot, syscall.MAP_SHARED)
+}
+
+// munmap releases a mapping previously returned by mmap
+func munmap(mapping []byte) error {
+	return syscall.Munmap(mapping)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package fileflatdb
+
+import (
+	"errors"
+)
+
+var mmapSupported = false
+
+// mmap is only available on Linux, other platforms use positional reads
+func (f *File) mmap(length int64) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+// munmap ...
+func munmap(mapping []byte) error {
+	return nil
+}
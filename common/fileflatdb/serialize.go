@@ -1,12 +1,39 @@
 package fileflatdb
 
 import (
+	"errors"
 	"log"
+	"sync"
 
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tsfdsong/go-polkadot/common/db"
 	"github.com/tsfdsong/go-polkadot/common/triecodec"
 )
 
+// value flags, stored as the first byte of every value in versioned files
+const (
+	valueRaw byte = iota
+	valueSnappy
+	valueZstd
+)
+
+// values below this size are stored raw, compressing them gains nothing
+var compressMinSize = 64
+
+// maxKeySize is the size of the longest key stored by versioned files,
+// headerless files store keys of up to keySize
+var maxKeySize = 64
+
+// ErrKeyTooLarge is returned when writing a key longer than the file stores
+var ErrKeyTooLarge = errors.New("[fileflatdb] key too large")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
 // NibbleBuffer ...
 type NibbleBuffer struct {
 	Buffer  []byte
@@ -15,7 +42,14 @@ type NibbleBuffer struct {
 
 // Serializer ...
 type Serializer struct {
+	// IsLegacy is set for headerless files, where values carry no flag and
+	// IsCompressed alone decides whether they are snappy encoded. Their keys
+	// are walked with the nibbles of the unpadded key and leaves matched on
+	// their first keySize nibbles. Compaction migrates them to the current
+	// format.
+	IsLegacy     bool
 	IsCompressed bool
+	Compression  db.Compression
 }
 
 // NewSerializer ...
@@ -25,49 +59,145 @@ func NewSerializer() *Serializer {
 
 // DeserializeValue ...
 func (s *Serializer) DeserializeValue(value []byte) []uint8 {
-	if s.IsCompressed {
-		var dst []byte
-		decoded, err := snappy.Decode(dst, value)
+	if s.IsLegacy {
+		if s.IsCompressed {
+			return decodeSnappy(value)
+		}
+
+		return value
+	}
+
+	if len(value) == 0 {
+		return value
+	}
+
+	switch value[0] {
+	case valueRaw:
+		return value[1:]
+	case valueSnappy:
+		return decodeSnappy(value[1:])
+	case valueZstd:
+		initZstd()
+		decoded, err := zstdDecoder.DecodeAll(value[1:], nil)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("[fileflatdb/serialize] zstd decode error: %v", err)
 		}
 
 		return decoded
+	default:
+		log.Fatalf("[fileflatdb/serialize] unknown value flag %d", value[0])
 	}
 
-	return value
+	return nil
 }
 
 // SerializeValue ...
 func (s *Serializer) SerializeValue(value []uint8) []byte {
-	if s.IsCompressed {
-		var dst []byte
-		return snappy.Encode(dst, value)
+	if s.IsLegacy {
+		if s.IsCompressed {
+			var dst []byte
+			return snappy.Encode(dst, value)
+		}
+
+		return value
 	}
 
-	return value
+	if s.Compression != db.CompressionNone && len(value) >= compressMinSize {
+		var encoded []byte
+		flag := valueSnappy
+		switch s.Compression {
+		case db.CompressionSnappy:
+			encoded = snappy.Encode(nil, value)
+		case db.CompressionZstd:
+			initZstd()
+			encoded = zstdEncoder.EncodeAll(value, nil)
+			flag = valueZstd
+		default:
+			log.Fatalf("[fileflatdb/serialize] unknown compression %d", s.Compression)
+		}
+
+		// NOTE: incompressible values are kept raw
+		if len(encoded) < len(value) {
+			return append([]byte{flag}, encoded...)
+		}
+	}
+
+	return append([]byte{valueRaw}, value...)
+}
+
+// MaxKeySize returns the size of the longest key the file stores
+func (s *Serializer) MaxKeySize() int {
+	if s.IsLegacy {
+		return keySize
+	}
+
+	return maxKeySize
+}
+
+// storedKeySize returns the size of the keys as stored in the leaves
+func (s *Serializer) storedKeySize() int {
+	if s.IsLegacy {
+		return keySize
+	}
+
+	return maxKeySize + 1
 }
 
 // SerializeKey ...
 func (s *Serializer) SerializeKey(value []uint8) *NibbleBuffer {
-	if len(value) > keySize {
-		log.Fatalf("too large, expected <= 32 bytes, got %d", len(value))
+	if len(value) > s.MaxKeySize() {
+		log.Fatalf("too large, expected <= %d bytes, got %d", s.MaxKeySize(), len(value))
 	}
 
-	var b []byte
-	if len(value) == keySize {
-		b = value
-	} else {
-		tmp := make([]byte, keySize)
-		for i := range value {
-			tmp[i] = value[i]
-		}
+	b := make([]byte, s.storedKeySize())
+	copy(b, value)
 
-		b = tmp[:]
+	if s.IsLegacy {
+		return &NibbleBuffer{
+			Buffer:  b,
+			Nibbles: triecodec.ToNibbles(value),
+		}
 	}
 
+	// NOTE: the key is stored zero padded, followed by its length. The nibbles
+	// are taken from all of it, so keys differing only by trailing zeros get
+	// leaves of their own and the tree stays in the byte order of the keys.
+	b[maxKeySize] = byte(len(value))
+
 	return &NibbleBuffer{
 		Buffer:  b,
-		Nibbles: triecodec.ToNibbles(value),
+		Nibbles: triecodec.ToNibbles(b),
 	}
 }
+
+// DeserializeKey returns the key stored in a leaf, headerless files only
+// store it padded to keySize
+func (s *Serializer) DeserializeKey(stored []byte) []uint8 {
+	if s.IsLegacy {
+		return stored[:keySize]
+	}
+
+	return stored[:stored[maxKeySize]]
+}
+
+func decodeSnappy(value []byte) []byte {
+	var dst []byte
+	decoded, err := snappy.Decode(dst, value)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return decoded
+}
+
+func initZstd() {
+	zstdOnce.Do(func() {
+		var err error
+		if zstdEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression)); err != nil {
+			log.Fatalf("[fileflatdb/serialize] zstd encoder error: %v", err)
+		}
+		if zstdDecoder, err = zstd.NewReader(nil); err != nil {
+			log.Fatalf("[fileflatdb/serialize] zstd decoder error: %v", err)
+		}
+	})
+}
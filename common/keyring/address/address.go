@@ -10,11 +10,12 @@ import (
 	"github.com/mr-tron/base58/base58"
 )
 
+// NOTE: the context the checksum hash is salted with
+var checksumPrefix = []byte("SS58PRE")
+
 // Encode ...
 func Encode(b []byte, prefix PrefixEnum) (string, error) {
-	var (
-		allowed, isPublicKey bool
-	)
+	var allowed bool
 
 	l := len(b)
 	for idx := range DefaultAllowedDecodedLengths {
@@ -27,97 +28,162 @@ func Encode(b []byte, prefix PrefixEnum) (string, error) {
 		return "", ErrDecodedLengthNotAllowed
 	}
 
-	if l == 32 {
-		isPublicKey = true
-	}
-
 	if prefix == nil {
 		prefix = DefaultPrefix
 	}
 
-	input := u8util.Concat([]uint8{uint8(prefix.Type())}, b)
-	hash := crypto.NewBlake2b512(input)
-	if hash == nil {
-		return "", errors.New("nil blake hash")
+	encodedPrefix, err := encodePrefix(prefix.Type())
+	if err != nil {
+		return "", err
 	}
 
-	ending := 1
-	if isPublicKey {
-		ending = 2
-	}
-	if len(hash) < ending {
-		return "", errors.New("invalid hash length")
+	input := u8util.Concat(encodedPrefix, b)
+	hash := checksum(input)
+	if hash == nil {
+		return "", errors.New("nil blake hash")
 	}
 
-	return base58.Encode(append(input, hash[0:ending]...)), nil
+	return base58.Encode(append(input, hash[0:checksumLength(l)]...)), nil
 }
 
-// Decode ...
+// Decode returns the key of an address of prefix, or of one of
+// DefaultAllowedPrefix when prefix is nil
 func Decode(encoded string, prefix PrefixEnum) ([]byte, error) {
-	var (
-		allowed, isPublicKey bool
-	)
-
 	if hexutil.ValidHex(encoded) {
 		return hexutil.ToUint8Slice(encoded, -1)
 	}
 
-	decoded, err := base58.Decode(encoded)
+	decoded, decodedPrefix, err := DecodeWithPrefix(encoded)
 	if err != nil {
 		return nil, err
 	}
 
-	l := len(decoded)
-	for idx := range DefaultAllowedEncodedLengths {
-		if l == DefaultAllowedEncodedLengths[idx] {
+	if prefix != nil {
+		if decodedPrefix.Type() != prefix.Type() {
+			return nil, ErrPrefixNotAllowed
+		}
+
+		return decoded, nil
+	}
+
+	// note: the registry keeps growing, networks missing from Networks are
+	// valid all the same
+	if DefaultAllowedPrefix == nil {
+		return decoded, nil
+	}
+
+	for idx := range DefaultAllowedPrefix {
+		if DefaultAllowedPrefix[idx].Type() == decodedPrefix.Type() {
+			return decoded, nil
+		}
+	}
+
+	return nil, ErrPrefixNotAllowed
+}
+
+// DecodeWithPrefix returns the key and the prefix of an address, whatever the
+// prefix is
+func DecodeWithPrefix(encoded string) ([]byte, PrefixEnum, error) {
+	decoded, err := base58.Decode(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix, prefixLength, err := decodePrefix(decoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the key is followed by its 1 or 2 byte checksum
+	var allowed bool
+	var keyLength int
+	for idx := range DefaultAllowedDecodedLengths {
+		l := DefaultAllowedDecodedLengths[idx]
+		if prefixLength+l+checksumLength(l) == len(decoded) {
 			allowed = true
+			keyLength = l
 			break
 		}
 	}
 	if !allowed {
-		return nil, ErrDecodedLengthNotAllowed
+		return nil, nil, ErrDecodedLengthNotAllowed
 	}
 
-	// TODO Unless it is an "use everywhere" prefix, throw an error
-	// if (decoded[0] !== prefix) {
-	//   console.log(`WARN: Expected ${prefix}, found ${decoded[0]}`);
-	// }
+	ending := prefixLength + keyLength
+
+	// calculate the hash and do the checksum byte checks
+	hash := checksum(decoded[0:ending])
+	if hash == nil {
+		return nil, nil, errors.New("nil blake hash")
+	}
 
-	if l == 35 {
-		isPublicKey = true
+	for idx := range decoded[ending:] {
+		if decoded[ending+idx] != (*hash)[idx] {
+			return nil, nil, ErrInvalidChecksum
+		}
 	}
 
-	// non-publicKeys has 1 byte checksums, else default to 2
-	ending := l - 1
-	if isPublicKey {
-		ending = l - 2
+	return decoded[prefixLength:ending], prefix, nil
+}
+
+// checksum ...
+func checksum(input []byte) *crypto.Blake2b512Hash {
+	return crypto.NewBlake2b512(u8util.Concat(checksumPrefix, input))
+}
+
+// checksumLength returns the checksum length of a key, public keys and
+// account IDs have 2 byte checksums, the shorter keys 1
+func checksumLength(keyLength int) int {
+	if keyLength >= 32 {
+		return 2
 	}
-	if len(decoded) < ending {
-		return nil, errors.New("invalid decoded length")
+
+	return 1
+}
+
+// encodePrefix encodes prefixes below 64 in one byte, the others in two with
+// the 0b01 marker in the high bits of the first
+func encodePrefix(prefix prefixEnum) ([]byte, error) {
+	if !isValidPrefix(prefix) {
+		return nil, ErrInvalidPrefix
 	}
 
-	// calculate the hash and do the checksum byte checks
-	hash := crypto.NewBlake2b512(decoded[0:ending])
-	if hash == nil {
-		return nil, errors.New("nil blake hash")
+	if prefix < 64 {
+		return []byte{uint8(prefix)}, nil
 	}
 
-	// run checks
-	switch isPublicKey {
-	case true:
-		{
-			if l < 2 || decoded[l-2] != hash[0] || decoded[l-1] != hash[1] {
-				return nil, ErrInvalidChecksum
-			}
+	return []byte{
+		uint8((prefix&0xfc)>>2) | 0x40,
+		uint8(prefix>>8) | uint8((prefix&0x03)<<6),
+	}, nil
+}
+
+// decodePrefix returns the prefix an address starts with and its length
+func decodePrefix(decoded []byte) (prefixEnum, int, error) {
+	if len(decoded) == 0 {
+		return 0, 0, ErrDecodedLengthNotAllowed
+	}
+
+	var prefix prefixEnum
+	var length int
+	switch {
+	case decoded[0] < 64:
+		prefix = prefixEnum(decoded[0])
+		length = 1
+	case decoded[0] < 128:
+		if len(decoded) < 2 {
+			return 0, 0, ErrDecodedLengthNotAllowed
 		}
 
+		prefix = prefixEnum(decoded[0]&0x3f)<<2 | prefixEnum(decoded[1]>>6) | prefixEnum(decoded[1]&0x3f)<<8
+		length = 2
 	default:
-		{
-			if l < 1 || decoded[l-1] != hash[0] {
-				return nil, ErrInvalidChecksum
-			}
-		}
+		return 0, 0, ErrInvalidPrefix
+	}
+
+	if !isValidPrefix(prefix) {
+		return 0, 0, ErrInvalidPrefix
 	}
 
-	return decoded[1:ending], nil
+	return prefix, length, nil
 }
@@ -17,32 +17,32 @@ func TestEncode(t *testing.T) {
 			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79},
 			nil,
 			true,
-			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDtZ",
+			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQua",
 		}, {
 			[]byte{215, 86, 142, 95, 10, 126, 218, 103, 168, 38, 145, 255, 55, 154, 196, 187, 164, 249, 201, 184, 89, 254, 119, 155, 93, 70, 54, 59, 97, 173, 45, 185},
 			nil,
 			true,
-			"5Gw3s7q4QLkSWwknsiPtjujPv3XM4Trxi5d4PgKMMk3gfGTE",
+			"5Gw3s7q4QLkSWwknsiPtjujPv3XM4Trxi5d4PgKMMk3gfGqm",
 		}, {
 			[]byte{163, 155, 255, 175, 56, 21, 16, 70, 7, 55, 3, 233, 117, 111, 140, 228, 158, 235, 160, 83, 65, 81, 247, 227, 251, 198, 170, 24, 95, 6, 179, 164},
 			nil,
 			true,
-			"5FmE1Adpwp1bT1oY95w59RiSPVu9QwzBGjKsE2hxemD2AFs8",
+			"5FmE1Adpwp1bT1oY95w59RiSPVu9QwzBGjKsE2hxemD2AJQg",
 		}, {
 			[]byte{191, 200, 35, 170, 117, 195, 0, 88, 238, 236, 33, 171, 226, 194, 214, 183, 36, 116, 24, 164, 175, 137, 214, 122, 32, 132, 194, 172, 134, 77, 160, 128},
 			nil,
 			true,
-			"5GQATTPFqkfze7kbGuvezhV921FwSp6Xyr8FMW1pmi6LjDuu",
+			"5GQATTPFqkfze7kbGuvezhV921FwSp6Xyr8FMW1pmi6LjDDh",
 		}, {
 			[]byte{13, 113, 209, 169, 202, 214, 242, 171, 119, 52, 53, 167, 222, 193, 186, 192, 25, 153, 77, 5, 209, 221, 94, 179, 16, 130, 17, 220, 242, 92, 157, 30},
 			nil,
 			true,
-			"5CNLHq4doqBbrrxLCxAakEgaEvef5tjSrN7QqJwcWzNd7W7k",
+			"5CNLHq4doqBbrrxLCxAakEgaEvef5tjSrN7QqJwcWzNd7BNd",
 		}, {
 			[]byte{210, 222, 115, 148, 174, 4, 122, 85, 2, 173, 154, 219, 156, 198, 159, 246, 254, 72, 64, 51, 191, 206, 135, 77, 119, 93, 169, 71, 72, 124, 216, 50},
 			nil,
 			true,
-			"5GqBzeuVYJBorP3oP7FgheoP5nb2twFeDUFZhoBhX7ExYsia",
+			"5GqBzeuVYJBorP3oP7FgheoP5nb2twFeDUFZhoBhX7ExYsqu",
 		},
 		{
 			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79}[0:30],
@@ -54,31 +54,71 @@ func TestEncode(t *testing.T) {
 			[]byte{1},
 			nil,
 			true,
-			"F7L6",
+			"F7NZ",
 		},
 		{
 			[]byte{1},
 			SixtyEight,
 			true,
-			"Pqt7",
+			"355zuM",
 		},
 		{
 			[]byte{0, 1},
 			SixtyEight,
 			true,
-			"2jpAJz",
+			"A934EhZ",
 		},
 		{
 			[]byte{1, 2, 3, 4},
 			SixtyEight,
 			true,
-			"as7QnGQ7",
+			"452vcTS3vc",
 		},
 		{
 			[]byte{42, 44, 10, 0, 0, 0, 0, 0},
 			SixtyEight,
 			true,
-			"4q7qY5RBG7Z4xX",
+			"M5zjV2sQNCHqgfq",
+		},
+		{
+			// NOTE: the well-known address of Alice
+			[]byte{212, 53, 147, 199, 21, 253, 211, 28, 97, 20, 26, 189, 4, 169, 159, 214, 130, 44, 133, 88, 133, 76, 205, 227, 154, 86, 132, 231, 165, 109, 162, 125},
+			nil,
+			true,
+			"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY",
+		},
+		{
+			[]byte{212, 53, 147, 199, 21, 253, 211, 28, 97, 20, 26, 189, 4, 169, 159, 214, 130, 44, 133, 88, 133, 76, 205, 227, 154, 86, 132, 231, 165, 109, 162, 125},
+			Polkadot,
+			true,
+			"15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5",
+		},
+		{
+			[]byte{212, 53, 147, 199, 21, 253, 211, 28, 97, 20, 26, 189, 4, 169, 159, 214, 130, 44, 133, 88, 133, 76, 205, 227, 154, 86, 132, 231, 165, 109, 162, 125},
+			Kusama,
+			true,
+			"HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F",
+		},
+		{
+			// two byte prefix
+			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79},
+			prefixEnum(255),
+			true,
+			"yGHU8YKprxHbHdEv7oUK4rzMZXtsdhcXVG2CAMyC9WhzhjH2k",
+		},
+		{
+			// the 33 byte public key of an ecdsa pair
+			[]byte{2, 10, 16, 145, 52, 31, 229, 102, 75, 250, 23, 130, 213, 224, 71, 121, 104, 144, 104, 201, 22, 176, 76, 179, 101, 236, 49, 83, 117, 86, 132, 217, 161},
+			nil,
+			true,
+			"KW39r9CJjAVzmkf9zQ4YDb2hqfAVGdRqn53eRqyruqpxAP5YL",
+		},
+		{
+			// reserved prefix
+			[]byte{1},
+			prefixEnum(46),
+			false,
+			"",
 		},
 	} {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
@@ -104,32 +144,32 @@ func TestDecode(t *testing.T) {
 		out    []byte
 	}{
 		{
-			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDtZ",
+			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQua",
 			nil,
 			true,
 			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79},
 		}, {
-			"5Gw3s7q4QLkSWwknsiPtjujPv3XM4Trxi5d4PgKMMk3gfGTE",
+			"5Gw3s7q4QLkSWwknsiPtjujPv3XM4Trxi5d4PgKMMk3gfGqm",
 			nil,
 			true,
 			[]byte{215, 86, 142, 95, 10, 126, 218, 103, 168, 38, 145, 255, 55, 154, 196, 187, 164, 249, 201, 184, 89, 254, 119, 155, 93, 70, 54, 59, 97, 173, 45, 185},
 		}, {
-			"5FmE1Adpwp1bT1oY95w59RiSPVu9QwzBGjKsE2hxemD2AFs8",
+			"5FmE1Adpwp1bT1oY95w59RiSPVu9QwzBGjKsE2hxemD2AJQg",
 			nil,
 			true,
 			[]byte{163, 155, 255, 175, 56, 21, 16, 70, 7, 55, 3, 233, 117, 111, 140, 228, 158, 235, 160, 83, 65, 81, 247, 227, 251, 198, 170, 24, 95, 6, 179, 164},
 		}, {
-			"5GQATTPFqkfze7kbGuvezhV921FwSp6Xyr8FMW1pmi6LjDuu",
+			"5GQATTPFqkfze7kbGuvezhV921FwSp6Xyr8FMW1pmi6LjDDh",
 			nil,
 			true,
 			[]byte{191, 200, 35, 170, 117, 195, 0, 88, 238, 236, 33, 171, 226, 194, 214, 183, 36, 116, 24, 164, 175, 137, 214, 122, 32, 132, 194, 172, 134, 77, 160, 128},
 		}, {
-			"5CNLHq4doqBbrrxLCxAakEgaEvef5tjSrN7QqJwcWzNd7W7k",
+			"5CNLHq4doqBbrrxLCxAakEgaEvef5tjSrN7QqJwcWzNd7BNd",
 			nil,
 			true,
 			[]byte{13, 113, 209, 169, 202, 214, 242, 171, 119, 52, 53, 167, 222, 193, 186, 192, 25, 153, 77, 5, 209, 221, 94, 179, 16, 130, 17, 220, 242, 92, 157, 30},
 		}, {
-			"5GqBzeuVYJBorP3oP7FgheoP5nb2twFeDUFZhoBhX7ExYsia",
+			"5GqBzeuVYJBorP3oP7FgheoP5nb2twFeDUFZhoBhX7ExYsqu",
 			nil,
 			true,
 			[]byte{210, 222, 115, 148, 174, 4, 122, 85, 2, 173, 154, 219, 156, 198, 159, 246, 254, 72, 64, 51, 191, 206, 135, 77, 119, 93, 169, 71, 72, 124, 216, 50},
@@ -141,31 +181,31 @@ func TestDecode(t *testing.T) {
 			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79}[0:30],
 		},
 		{
-			"F7L6",
+			"F7NZ",
 			nil,
 			true,
 			[]byte{1},
 		},
 		{
-			"Pqt7",
+			"355zuM",
 			SixtyEight,
 			true,
 			[]byte{1},
 		},
 		{
-			"2jpAJz",
+			"A934EhZ",
 			SixtyEight,
 			true,
 			[]byte{0, 1},
 		},
 		{
-			"as7QnGQ7",
+			"452vcTS3vc",
 			SixtyEight,
 			true,
 			[]byte{1, 2, 3, 4},
 		},
 		{
-			"4q7qY5RBG7Z4xX",
+			"M5zjV2sQNCHqgfq",
 			SixtyEight,
 			true,
 			[]byte{42, 44, 10, 0, 0, 0, 0, 0},
@@ -176,14 +216,40 @@ func TestDecode(t *testing.T) {
 			true,
 			[]byte{1, 2, 3, 4},
 		},
-		// TODO...
-		//{
-		//// invalid prefix
-		//"6GfvWUvHvU8otbZ7sFhXH4eYeMcKdUkL61P3nFy52efEPVUx",
-		//nil,
-		//false,
-		//nil,
-		//},
+		{
+			// reserved prefix
+			"5fwxxXfscitSxF8LS4RP1f3zmSkZJNyCxN4G6A7GFX6JhbeV",
+			nil,
+			false,
+			nil,
+		},
+		{
+			// another prefix than the expected one
+			"HJwasr8hvQU5fKvadiLQ1MJL6TctKGJeygV25p1stvrKLAb",
+			SixtyEight,
+			false,
+			nil,
+		},
+		{
+			"HJwasr8hvQU5fKvadiLQ1MJL6TctKGJeygV25p1stvrKLAb",
+			Kusama,
+			true,
+			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79},
+		},
+		{
+			// two byte prefix
+			"yGHU8YKprxHbHdEv7oUK4rzMZXtsdhcXVG2CAMyC9WhzhjH2k",
+			nil,
+			true,
+			[]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79},
+		},
+		{
+			// the checksum without the SS58PRE context
+			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDtZ",
+			nil,
+			false,
+			nil,
+		},
 		{
 			// invalid length
 			"y9EMHt34JJo4rWLSaxoLGdYXvjgSXEd4zHUnQgfNzwES8b",
@@ -193,14 +259,14 @@ func TestDecode(t *testing.T) {
 		},
 		{
 			// invalid checksum
-			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMa9cj",
+			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQyz",
 			nil,
 			false,
 			nil,
 		},
 		{
 			// invalid checksum
-			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDwU",
+			"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQuZ",
 			nil,
 			false,
 			nil,
@@ -221,3 +287,46 @@ func TestDecode(t *testing.T) {
 	}
 
 }
+
+func TestDecodeWithPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		in     string
+		prefix PrefixEnum
+	}{
+		{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", Substrate},
+		{"15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5", Polkadot},
+		{"yNa4gppwGAch5TwQLs1jZem7LFTtAAACH9H6S82i7gaTRTcxf", prefixEnum(MaxPrefix)},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			_, prefix, err := DecodeWithPrefix(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if prefix != tt.prefix {
+				t.Errorf("want %v; got %v", tt.prefix, prefix)
+			}
+		})
+	}
+
+	t.Run("outside the registry", func(t *testing.T) {
+		if _, err := Decode("yNa4gppwGAch5TwQLs1jZem7LFTtAAACH9H6S82i7gaTRTcxf", nil); err != nil {
+			t.Error(err)
+		}
+		if name := prefixEnum(MaxPrefix).String(); name != "prefixEnum(16383)" {
+			t.Errorf("expected no network name, got %v", name)
+		}
+	})
+
+	t.Run("outside the allowed networks", func(t *testing.T) {
+		DefaultAllowedPrefix = []PrefixEnum{Polkadot, Kusama}
+		defer func() { DefaultAllowedPrefix = nil }()
+
+		if _, err := Decode("yNa4gppwGAch5TwQLs1jZem7LFTtAAACH9H6S82i7gaTRTcxf", nil); err != ErrPrefixNotAllowed {
+			t.Errorf("want %v; got %v", ErrPrefixNotAllowed, err)
+		}
+		if _, err := Decode("15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5", nil); err != nil {
+			t.Error(err)
+		}
+	})
+}
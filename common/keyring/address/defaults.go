@@ -2,11 +2,10 @@ package address
 
 var (
 	// DefaultAllowedDecodedLengths ...
-	DefaultAllowedDecodedLengths = []int{1, 2, 4, 8, 32}
-	// DefaultAllowedEncodedLengths ...
-	DefaultAllowedEncodedLengths = []int{3, 4, 6, 10, 35}
-	// DefaultAllowedPrefix ...
-	DefaultAllowedPrefix = AllPrefixEnums()
+	DefaultAllowedDecodedLengths = []int{1, 2, 4, 8, 32, 33}
+	// DefaultAllowedPrefix is the list of the networks Decode accepts, nil
+	// accepting every prefix, whether the registry names it or not
+	DefaultAllowedPrefix []PrefixEnum
 	// DefaultPrefix ...
 	DefaultPrefix = FortyTwo
 )
@@ -10,7 +10,7 @@ func TestSetDefaultPrefix(t *testing.T) {
 		t.Error(err)
 	}
 
-	if result != "Pqt7" {
-		t.Errorf("expected: Pqt7, received: %s", result)
+	if result != "355zuM" {
+		t.Errorf("expected: 355zuM, received: %s", result)
 	}
 }
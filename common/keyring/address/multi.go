@@ -0,0 +1,201 @@
+package address
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/tsfdsong/go-polkadot/common/crypto"
+	"github.com/tsfdsong/go-polkadot/common/u8compact"
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+var (
+	// NOTE: the module IDs the account IDs of pallet_multisig, pallet_utility
+	// and pallet_proxy are salted with
+	utilityPrefix = []byte("modlpy/utilisuba")
+	proxyPrefix   = []byte("modlpy/proxy____")
+)
+
+var (
+	// ErrInvalidThreshold ...
+	ErrInvalidThreshold = errors.New("threshold must be between 1 and the number of signatories")
+	// ErrDuplicateSignatory ...
+	ErrDuplicateSignatory = errors.New("duplicate signatory")
+	// ErrInvalidAccountID ...
+	ErrInvalidAccountID = errors.New("account IDs must be 32 bytes")
+)
+
+// CreateKeyMulti returns the account ID of the multisig of who and threshold,
+// the blake2-256 of the SCALE encoded ("modlpy/utilisuba", sorted who,
+// threshold) as pallet_multisig derives it
+func CreateKeyMulti(who [][]byte, threshold uint16) ([]byte, error) {
+	if err := checkThreshold(len(who), threshold); err != nil {
+		return nil, err
+	}
+
+	sorted, err := sortAccountIDs(who)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := u8util.Concat(
+		utilityPrefix,
+		u8compact.CompactToUint8Slice(big.NewInt(int64(len(sorted))), u8compact.DefaultBitLength),
+	)
+	for idx := range sorted {
+		encoded = u8util.Concat(encoded, sorted[idx])
+	}
+	encoded = u8util.Concat(encoded, uint16ToBytes(threshold))
+
+	hash := crypto.NewBlake2b256(encoded)
+	return hash[:], nil
+}
+
+// EncodeMultiAddress returns the address of the multisig of the signatory
+// addresses who and threshold
+func EncodeMultiAddress(who []string, threshold uint16, prefix PrefixEnum) (string, error) {
+	ids, err := decodeAll(who)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := CreateKeyMulti(ids, threshold)
+	if err != nil {
+		return "", err
+	}
+
+	return Encode(key, prefix)
+}
+
+// CreateKeyDerived returns the account ID of the utility.as_derivative
+// sub-account index of who, the blake2-256 of the SCALE encoded
+// ("modlpy/utilisuba", who, index)
+func CreateKeyDerived(who []byte, index uint16) ([]byte, error) {
+	if len(who) != 32 {
+		return nil, ErrInvalidAccountID
+	}
+
+	hash := crypto.NewBlake2b256(u8util.Concat(utilityPrefix, who, uint16ToBytes(index)))
+	return hash[:], nil
+}
+
+// EncodeDerivedAddress returns the address of the sub-account index of the
+// address who
+func EncodeDerivedAddress(who string, index uint16, prefix PrefixEnum) (string, error) {
+	id, err := Decode(who, nil)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := CreateKeyDerived(id, index)
+	if err != nil {
+		return "", err
+	}
+
+	return Encode(key, prefix)
+}
+
+// CreateKeyPure returns the account ID of the pure proxy who spawned with
+// proxy.create_pure, the blake2-256 of the SCALE encoded ("modlpy/proxy____",
+// who, height, extIndex, proxyType, index). height and extIndex are the block
+// and the extrinsic index of the call and proxyType the index of the proxy
+// type in the enum of the runtime. The encoding follows pallet_proxy but
+// hasn't been checked against the pure proxy of a chain.
+func CreateKeyPure(who []byte, proxyType uint8, index uint16, height, extIndex uint32) ([]byte, error) {
+	if len(who) != 32 {
+		return nil, ErrInvalidAccountID
+	}
+
+	encoded := u8util.Concat(
+		proxyPrefix,
+		who,
+		uint32ToBytes(height),
+		uint32ToBytes(extIndex),
+		[]byte{proxyType},
+		uint16ToBytes(index),
+	)
+
+	hash := crypto.NewBlake2b256(encoded)
+	return hash[:], nil
+}
+
+// EncodePureAddress returns the address of the pure proxy of the address who
+func EncodePureAddress(who string, proxyType uint8, index uint16, height, extIndex uint32, prefix PrefixEnum) (string, error) {
+	id, err := Decode(who, nil)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := CreateKeyPure(id, proxyType, index, height, extIndex)
+	if err != nil {
+		return "", err
+	}
+
+	return Encode(key, prefix)
+}
+
+// checkThreshold checks the threshold of a multisig of count signatories
+func checkThreshold(count int, threshold uint16) error {
+	if threshold == 0 || int(threshold) > count {
+		return ErrInvalidThreshold
+	}
+
+	return nil
+}
+
+// sortAccountIDs returns a sorted copy of ids, which the runtime requires to
+// be distinct
+func sortAccountIDs(ids [][]byte) ([][]byte, error) {
+	sorted := make([][]byte, len(ids))
+	for idx := range ids {
+		if len(ids[idx]) != 32 {
+			return nil, ErrInvalidAccountID
+		}
+
+		sorted[idx] = ids[idx]
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	for idx := 1; idx < len(sorted); idx++ {
+		if bytes.Equal(sorted[idx-1], sorted[idx]) {
+			return nil, ErrDuplicateSignatory
+		}
+	}
+
+	return sorted, nil
+}
+
+// decodeAll ...
+func decodeAll(addrs []string) ([][]byte, error) {
+	ids := make([][]byte, len(addrs))
+	for idx := range addrs {
+		id, err := Decode(addrs[idx], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[idx] = id
+	}
+
+	return ids, nil
+}
+
+// uint16ToBytes ...
+func uint16ToBytes(n uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, n)
+	return b
+}
+
+// uint32ToBytes ...
+func uint32ToBytes(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, n)
+	return b
+}
@@ -0,0 +1,108 @@
+package address
+
+import (
+	"fmt"
+	"testing"
+)
+
+var (
+	alice   = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	bob     = "5FHneW46xGXgs5mUiveU4sbTyGBzmstUspZC92UhjJM694ty"
+	charlie = "5FLSigC9HGRKVhB9FiEo4Y3koPsNmBmLJbpXg2mp1hXcS59Y"
+)
+
+func TestEncodeMultiAddress(t *testing.T) {
+	for i, tt := range []struct {
+		who       []string
+		threshold uint16
+		prefix    PrefixEnum
+		err       error
+		out       string
+	}{
+		// NOTE: the multisig of the polkadot.js docs
+		{[]string{alice, bob, charlie}, 2, Substrate, nil, "5DjYJStmdZ2rcqXbXGX7TW85JsrW6uG4y9MUcLq2BoPMpRA7"},
+		// note: the order of the signatories doesn't matter
+		{[]string{charlie, alice, bob}, 2, Substrate, nil, "5DjYJStmdZ2rcqXbXGX7TW85JsrW6uG4y9MUcLq2BoPMpRA7"},
+		{[]string{alice, bob, charlie}, 2, Polkadot, nil, "12fqSn9qVLJL4NY7Uua7bexEAVr9oCpD3e5xmdpNjtQszzBt"},
+		{[]string{bob, alice}, 1, Substrate, nil, "5DnowFkXHuvv5PwXAedoneD7SKmci7WRkfpS2izURzCrBCxj"},
+		{[]string{alice, bob}, 0, Substrate, ErrInvalidThreshold, ""},
+		{[]string{alice, bob}, 3, Substrate, ErrInvalidThreshold, ""},
+		{[]string{alice, alice}, 2, Substrate, ErrDuplicateSignatory, ""},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := EncodeMultiAddress(tt.who, tt.threshold, tt.prefix)
+			if err != tt.err {
+				t.Fatalf("want %v; got %v", tt.err, err)
+			}
+
+			if out != tt.out {
+				t.Errorf("want %v; got %v", tt.out, out)
+			}
+		})
+	}
+
+	t.Run("rejects short account IDs", func(t *testing.T) {
+		if _, err := CreateKeyMulti([][]byte{{1, 2, 3}}, 1); err != ErrInvalidAccountID {
+			t.Errorf("want %v; got %v", ErrInvalidAccountID, err)
+		}
+	})
+}
+
+func TestEncodeDerivedAddress(t *testing.T) {
+	// NOTE: computed apart from this package, in Python, from the SCALE
+	// encoding of utility.derivative_account_id. Not checked against a chain.
+	// TODO: replace with the addresses createKeyDerived of
+	// @polkadot/util-crypto returns for the same accounts and indexes
+	for i, tt := range []struct {
+		who    string
+		index  uint16
+		prefix PrefixEnum
+		out    string
+	}{
+		{alice, 0, Substrate, "5Ep769A4Ka6QrHYoPfzA1fTWRSXpf28vhdbWHWmkWmi4SNHi"},
+		{alice, 1, Substrate, "5HfyUeY7jWfArT21FcynErXqZUDBgHirZsSkZsQVje9Ner6m"},
+		{alice, 0, Polkadot, "13kQEUR8BMMtHpZKMK3A9pHfH4XUMKh4n8KzSom74rjacxii"},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := EncodeDerivedAddress(tt.who, tt.index, tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if out != tt.out {
+				t.Errorf("want %v; got %v", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestEncodePureAddress(t *testing.T) {
+	// NOTE: computed apart from this package, in Python, from the SCALE
+	// encoding of proxy.pure_account, ("modlpy/proxy____", who, height,
+	// extIndex, proxyType, index). These guard against regressions only, they
+	// are not checked against a chain.
+	// TODO: replace with the address of a pure proxy created on a chain, with
+	// the block and extrinsic index of its proxy.create_pure call
+	for i, tt := range []struct {
+		who       string
+		proxyType uint8
+		index     uint16
+		height    uint32
+		extIndex  uint32
+		out       string
+	}{
+		{alice, 0, 0, 0, 0, "5FyHcmKVnpK2r6FY4F4wmyrWGwoi55hTqj3zPNHk62Sv5PY1"},
+		{bob, 1, 2, 100, 3, "5DVUycrhEYg4CwXbUyHPUQkB7HXSzD4uzFehLrNfzU6u3VtC"},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := EncodePureAddress(tt.who, tt.proxyType, tt.index, tt.height, tt.extIndex, Substrate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if out != tt.out {
+				t.Errorf("want %v; got %v", tt.out, out)
+			}
+		})
+	}
+}
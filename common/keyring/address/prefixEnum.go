@@ -2,6 +2,7 @@ package address
 
 import (
 	"errors"
+	"strconv"
 	"strings"
 )
 
@@ -24,8 +25,90 @@ const (
 	SixtyNine prefixEnum = 69
 )
 
-// ErrUnknownPrefix ...
-var ErrUnknownPrefix = errors.New("prefix: unknown")
+const (
+	// Polkadot ...
+	Polkadot prefixEnum = 0
+	// BareSr25519 ...
+	BareSr25519 prefixEnum = 1
+	// Kusama ...
+	Kusama prefixEnum = 2
+	// BareEd25519 ...
+	BareEd25519 prefixEnum = 3
+	// Substrate is the generic prefix, used by development chains
+	Substrate prefixEnum = 42
+	// BareSecp256k1 ...
+	BareSecp256k1 prefixEnum = 43
+)
+
+// MaxPrefix is the largest prefix, two byte prefixes hold 14 bits
+const MaxPrefix = 16383
+
+var (
+	// ErrUnknownPrefix ...
+	ErrUnknownPrefix = errors.New("prefix: unknown")
+	// ErrInvalidPrefix ...
+	ErrInvalidPrefix = errors.New("prefix: invalid")
+)
+
+// Network is an entry of the SS58 registry
+type Network struct {
+	Prefix      prefixEnum
+	Network     string
+	DisplayName string
+}
+
+// Networks is the registry of network prefixes
+var Networks = []Network{
+	{Polkadot, "polkadot", "Polkadot Relay Chain"},
+	{BareSr25519, "BareSr25519", "Bare 32-bit Schnorr/Ristretto (S/R 25519) public key."},
+	{Kusama, "kusama", "Kusama Relay Chain"},
+	{BareEd25519, "BareEd25519", "Bare 32-bit Ed25519 public key."},
+	{4, "katalchain", "Katal Chain"},
+	{5, "astar", "Astar Network"},
+	{6, "bifrost", "Bifrost"},
+	{7, "edgeware", "Edgeware"},
+	{8, "karura", "Karura"},
+	{9, "reynolds", "Laminar Reynolds Canary"},
+	{10, "acala", "Acala"},
+	{11, "laminar", "Laminar"},
+	{12, "polymesh", "Polymesh"},
+	{13, "integritee", "Integritee"},
+	{14, "totem", "Totem"},
+	{16, "kulupu", "Kulupu"},
+	{18, "darwinia", "Darwinia Network"},
+	{20, "stafi", "Stafi"},
+	{22, "dock-mainnet", "Dock Mainnet"},
+	{28, "subsocial", "Subsocial"},
+	{30, "phala", "Phala Network"},
+	{31, "litentry", "Litentry Network"},
+	{32, "robonomics", "Robonomics"},
+	{33, "datahighway", "DataHighway"},
+	{36, "centrifuge", "Centrifuge Chain"},
+	{37, "nodle", "Nodle Chain"},
+	{38, "kilt", "KILT Spiritnet"},
+	{Substrate, "substrate", "Substrate"},
+	{BareSecp256k1, "BareSecp256k1", "Bare 32-bit ECDSA SECP-256k1 public key."},
+	{44, "chainx", "ChainX"},
+	{63, "hydradx", "HydraDX"},
+	{65, "aventus", "Aventus Mainnet"},
+	{66, "crust", "Crust Network"},
+	{67, "genshiro", "Genshiro Network"},
+	{SixtyEight, "equilibrium", "Equilibrium Network"},
+	{SixtyNine, "sora", "SORA Network"},
+	{73, "zeitgeist", "Zeitgeist"},
+	{77, "manta", "Manta network"},
+	{78, "calamari", "Calamari: Manta Canary Network"},
+	{88, "polkadex", "Polkadex Mainnet"},
+	{128, "clover", "Clover Finance"},
+	{136, "altair", "Altair"},
+	{172, "parallel", "Parallel"},
+	{255, "quartz_mainnet", "QUARTZ by UNIQUE"},
+	{1284, "moonbeam", "Moonbeam"},
+	{1285, "moonriver", "Moonriver"},
+	{2032, "interlay", "Interlay"},
+	{2092, "kintsugi", "Kintsugi"},
+	{10041, "basilisk", "Basilisk"},
+}
 
 // PrefixEnum ...
 type PrefixEnum interface {
@@ -38,17 +121,35 @@ func (p prefixEnum) Type() prefixEnum {
 	return p
 }
 
-// AllPrefixEnums returns all of the prefix enums
+// note: the name comes from the registry, the prefixes don't map to one name
+// per constant so the 'stringer' tool can't generate it
+func (p prefixEnum) String() string {
+	for idx := range Networks {
+		if Networks[idx].Prefix == p {
+			return Networks[idx].Network
+		}
+	}
+
+	return "prefixEnum(" + strconv.FormatInt(int64(p), 10) + ")"
+}
+
+// NewPrefix returns the prefix of a network missing from the registry
+func NewPrefix(prefix int) (PrefixEnum, error) {
+	if !isValidPrefix(prefixEnum(prefix)) {
+		return nil, ErrInvalidPrefix
+	}
+
+	return prefixEnum(prefix), nil
+}
+
+// AllPrefixEnums returns the prefixes of the registry
 func AllPrefixEnums() []PrefixEnum {
-	return []PrefixEnum{
-		Zero,
-		One,
-		Three,
-		FortyTwo,
-		FortyThree,
-		SixtyEight,
-		SixtyNine,
+	prefixes := make([]PrefixEnum, len(Networks))
+	for idx := range Networks {
+		prefixes[idx] = Networks[idx].Prefix
 	}
+
+	return prefixes
 }
 
 // PrefixEnumFromString ...
@@ -64,11 +165,23 @@ func PrefixEnumFromString(s string) (PrefixEnum, error) {
 		return FortyTwo, nil
 	case "FORTYTHREE":
 		return FortyThree, nil
-	case "SIXYEIGHT":
+	case "SIXYEIGHT", "SIXTYEIGHT":
 		return SixtyEight, nil
 	case "SIXTYNINE":
 		return SixtyNine, nil
-	default:
-		return nil, ErrUnknownPrefix
 	}
+
+	for idx := range Networks {
+		if strings.EqualFold(Networks[idx].Network, s) {
+			return Networks[idx].Prefix, nil
+		}
+	}
+
+	return nil, ErrUnknownPrefix
+}
+
+// isValidPrefix ...
+func isValidPrefix(prefix prefixEnum) bool {
+	// note: 46 and 47 are reserved
+	return prefix >= 0 && prefix <= MaxPrefix && prefix != 46 && prefix != 47
 }
@@ -7,4 +7,6 @@ var (
 	ErrDecodedLengthNotAllowed = errors.New("decoded length not allowed")
 	// ErrInvalidChecksum ...
 	ErrInvalidChecksum = errors.New("Invalid decoded address checksum")
+	// ErrPrefixNotAllowed ...
+	ErrPrefixNotAllowed = errors.New("address prefix not allowed")
 )
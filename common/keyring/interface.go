@@ -10,16 +10,20 @@ import (
 type InterfaceKeyRing interface {
 	DecodeAddress(encoded []byte) ([]byte, error)
 	EncodeAddress(key []byte) (string, error)
+	AddressPrefix() address.PrefixEnum
 	SetAddressPrefix(prefix address.PrefixEnum) error
 	AddPair(pair *pair.Pair) (*pair.Pair, error)
-	AddFromAddress(addr []byte, meta keytypes.Meta, defaultEncoded []byte) (*pair.Pair, error)
-	AddFromMnemonic(mn, password string, meta keytypes.Meta) (*pair.Pair, error)
-	AddFromSeed(seed []byte, meta keytypes.Meta) (*pair.Pair, error)
+	AddFromAddress(addr []byte, meta keytypes.Meta, defaultEncoded []byte, keyType pair.KeyType) (*pair.Pair, error)
+	AddFromMnemonic(mn, password string, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error)
+	AddFromSeed(seed []byte, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error)
+	AddFromURI(uri string, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error)
 	AddFromJSON(data []byte, password *string) (*pair.Pair, error)
 	GetPair(addr []byte) (*pair.Pair, error)
 	GetPairs() ([]*pair.Pair, error)
-	GetPublicKeys() ([][32]byte, error)
+	GetPublicKeys() ([][]byte, error)
 	RemovePair(addr []byte) error
+	SetMeta(addr []byte, meta keytypes.Meta) error
 	// note: change to Marshal? Add Unmarshal?
 	ToJSON(addr []byte, password *string) ([]byte, error)
+	Unlock(addr []byte, password string) (*pair.Pair, error)
 }
@@ -1,95 +1,255 @@
 package keyring
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/tsfdsong/go-polkadot/common/crypto"
 	"github.com/tsfdsong/go-polkadot/common/keyring/address"
 	"github.com/tsfdsong/go-polkadot/common/keyring/pair"
+	"github.com/tsfdsong/go-polkadot/common/keyring/suri"
 	keytypes "github.com/tsfdsong/go-polkadot/common/keyring/types"
 	"github.com/tsfdsong/go-polkadot/common/mnemonic"
 )
 
-// New ...
+// New returns a keyring encoding addresses with address.DefaultPrefix
 func New() (*KeyRing, error) {
+	return NewWithPrefix(address.DefaultPrefix)
+}
+
+// NewWithPrefix returns a keyring encoding addresses with prefix
+func NewWithPrefix(prefix address.PrefixEnum) (*KeyRing, error) {
+	if prefix == nil {
+		return nil, errors.New("nil prefix")
+	}
+
+	return NewWithOptions(&Options{Prefix: prefix})
+}
+
+// NewWithOptions returns a keyring holding the pairs of options.Store, locked
+func NewWithOptions(options *Options) (*KeyRing, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	prefix := options.Prefix
+	if prefix == nil {
+		prefix = address.DefaultPrefix
+	}
+
+	// note: the pairs would be written in the clear
+	if options.Store != nil && options.Password == "" {
+		return nil, errors.New("a password is required to encrypt the stored pairs")
+	}
+
 	p, err := pair.NewPairs()
 	if err != nil {
 		return nil, err
 	}
 
-	return &KeyRing{
-		Pairs: p,
-	}, nil
+	k := &KeyRing{
+		Pairs:       p,
+		prefix:      prefix,
+		store:       options.Store,
+		password:    options.Password,
+		lockTimeout: options.LockTimeout,
+		lockTimers:  make(map[string]*time.Timer),
+	}
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// AddressPrefix ...
+func (k *KeyRing) AddressPrefix() address.PrefixEnum {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.prefix
 }
 
-// DecodeAddress ...
+// DecodeAddress decodes an address of the prefix of the keyring
 func (k *KeyRing) DecodeAddress(encoded []byte) ([]byte, error) {
-	return address.Decode(string(encoded), nil)
+	return address.Decode(string(encoded), k.AddressPrefix())
 }
 
 // EncodeAddress ...
 func (k *KeyRing) EncodeAddress(key []byte) (string, error) {
-	return address.Encode(key, nil)
+	return address.Encode(key, k.AddressPrefix())
 }
 
-// SetAddressPrefix ...
+// SetAddressPrefix sets the prefix of the keyring and of its pairs
 func (k *KeyRing) SetAddressPrefix(prefix address.PrefixEnum) error {
-	// note: is this really what we want? We won't be able to create multiple keyrings with different default prefixes...
-	address.SetDefaultPrefix(prefix)
+	if prefix == nil {
+		return errors.New("nil prefix")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.prefix = prefix
+
+	if k.Pairs == nil {
+		return nil
+	}
+
+	pairs, err := k.Pairs.All()
+	if err != nil {
+		return err
+	}
+
+	for idx := range pairs {
+		if err := pairs[idx].SetAddressPrefix(prefix); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// AddPair ...
+// AddPair adds a copy of pair, with the prefix of the keyring, and returns
+// the copy. pair itself is left as is.
 func (k *KeyRing) AddPair(pair *pair.Pair) (*pair.Pair, error) {
+	if pair == nil {
+		return nil, errors.New("nil pair")
+	}
+
+	copied, err := pair.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	return k.addPair(copied)
+}
+
+// addPair adds a pair the keyring owns, giving it the prefix of the keyring
+func (k *KeyRing) addPair(pair *pair.Pair) (*pair.Pair, error) {
 	if k.Pairs == nil {
 		return nil, errors.New("pairs is nil")
 	}
 
-	return k.Pairs.Add(pair)
+	// note: held so SetAddressPrefix can't miss the pair
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if err := pair.SetAddressPrefix(k.prefix); err != nil {
+		return nil, err
+	}
+
+	if _, err := k.Pairs.Add(pair); err != nil {
+		return nil, err
+	}
+
+	if err := k.persist(pair); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
 }
 
 // AddFromAddress ...
-func (k *KeyRing) AddFromAddress(addr []byte, meta keytypes.Meta, defaultEncoded []byte) (*pair.Pair, error) {
-	tmp, err := address.Decode(string(addr), nil)
+func (k *KeyRing) AddFromAddress(addr []byte, meta keytypes.Meta, defaultEncoded []byte, keyType pair.KeyType) (*pair.Pair, error) {
+	pub, err := address.Decode(string(addr), nil)
 	if err != nil {
 		return nil, err
 	}
-	var pub [32]byte
-	copy(pub[:], tmp)
+	// note: the address of an ecdsa pair is the hash of its public key, the
+	// public key has to be given in hex instead
+	if keyType == pair.Ecdsa && len(pub) != 33 {
+		return nil, errors.New("ecdsa pairs require the hex encoded public key")
+	}
 
 	// note: this pair will be locked bc no secret key ...
-	pair, err := pair.NewPair(pub, [64]byte{}, meta, defaultEncoded)
+	pair, err := pair.NewPair(keyType, pub, [64]byte{}, meta, defaultEncoded)
 	if err != nil {
 		return nil, err
 	}
 
-	return k.AddPair(pair)
+	return k.addPair(pair)
 }
 
 // AddFromMnemonic ...
-func (k *KeyRing) AddFromMnemonic(mn, password string, meta keytypes.Meta) (*pair.Pair, error) {
-	seed, err := mnemonic.ToSeed(mn, password)
+func (k *KeyRing) AddFromMnemonic(mn, password string, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error) {
+	seed, err := k.mnemonicToSeed(mn, password)
 	if err != nil {
 		return nil, err
 	}
 
-	return k.AddFromSeed(seed, meta)
+	return k.AddFromSeed(seed, meta, keyType)
 }
 
-// AddFromSeed ...
-func (k *KeyRing) AddFromSeed(seed []byte, meta keytypes.Meta) (*pair.Pair, error) {
-	pub, priv, err := crypto.NewNaclKeyPairFromSeed(seed)
+// AddFromSeed adds the pair of keyType generated from a 32 byte seed
+func (k *KeyRing) AddFromSeed(seed []byte, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error) {
+	pub, priv, err := keyPairFromSeed(keyType, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	// note: the secret is encoded once the pair is stored, Lock fails until then
+	pair, err := pair.NewPair(keyType, pub, priv, meta, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.addPair(pair)
+}
+
+// AddFromURI adds the pair of keyType of a secret URI, a mnemonic or 0x
+// prefixed hex seed followed by a derivation path and password, e.g.
+// "<mnemonic>//hard/soft///password". Only sr25519 supports soft junctions.
+func (k *KeyRing) AddFromURI(uri string, meta keytypes.Meta, keyType pair.KeyType) (*pair.Pair, error) {
+	parsed, err := suri.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var seed []byte
+	if strings.HasPrefix(parsed.Phrase, "0x") {
+		// note: the password only applies to mnemonics
+		seed, err = hex.DecodeString(parsed.Phrase[2:])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !mnemonic.Validate(parsed.Phrase) {
+			return nil, errors.New("invalid mnemonic")
+		}
+
+		password := ""
+		if parsed.Password != nil {
+			password = *parsed.Password
+		}
+
+		seed, err = k.mnemonicToSeed(parsed.Phrase, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pub, priv, err := keyPairFromSeed(keyType, seed)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: nil defaultEncoded?
-	pair, err := pair.NewPair(pub, priv, meta, nil)
+	for _, junction := range parsed.Path {
+		pub, priv, err = deriveKeyPair(keyType, priv, junction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pair, err := pair.NewPair(keyType, pub, priv, meta, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return k.AddPair(pair)
+	return k.addPair(pair)
 }
 
 // AddFromJSON ...
@@ -99,7 +259,7 @@ func (k *KeyRing) AddFromJSON(data []byte, password *string) (*pair.Pair, error)
 		return nil, err
 	}
 
-	return k.AddPair(pair)
+	return k.addPair(pair)
 }
 
 // GetPair ...
@@ -121,13 +281,13 @@ func (k *KeyRing) GetPairs() ([]*pair.Pair, error) {
 }
 
 // GetPublicKeys ...
-func (k *KeyRing) GetPublicKeys() ([][32]byte, error) {
+func (k *KeyRing) GetPublicKeys() ([][]byte, error) {
 	pairs, err := k.GetPairs()
 	if err != nil {
 		return nil, err
 	}
 
-	var pks [][32]byte
+	var pks [][]byte
 	for idx := range pairs {
 		pk, err := pairs[idx].PublicKey()
 		if err != nil {
@@ -146,7 +306,11 @@ func (k *KeyRing) RemovePair(addr []byte) error {
 		return errors.New("nil pairs")
 	}
 
-	return k.Pairs.Remove(addr)
+	if err := k.Pairs.Remove(addr); err != nil {
+		return err
+	}
+
+	return k.unpersist(addr)
 }
 
 // ToJSON ...
@@ -158,3 +322,74 @@ func (k *KeyRing) ToJSON(addr []byte, password *string) ([]byte, error) {
 
 	return pair.ToJSON(password)
 }
+
+// mnemonicToSeed ...
+func (k *KeyRing) mnemonicToSeed(mn, password string) ([]byte, error) {
+	if k.LegacyMnemonic {
+		return mnemonic.ToLegacySeed(mn, password)
+	}
+
+	return mnemonic.ToSeed(mn, password)
+}
+
+// keyPairFromSeed ...
+func keyPairFromSeed(keyType pair.KeyType, seed []byte) ([]byte, [64]byte, error) {
+	var (
+		pub  []byte
+		priv [64]byte
+		err  error
+	)
+	switch keyType {
+	case pair.Sr25519:
+		var key [32]byte
+		key, priv, err = crypto.NewSr25519KeyPairFromSeed(seed)
+		pub = key[:]
+	case pair.Ed25519:
+		var key [32]byte
+		key, priv, err = crypto.NewNaclKeyPairFromSeed(seed)
+		pub = key[:]
+	case pair.Ecdsa:
+		var secret [32]byte
+		pub, secret, err = crypto.NewSecp256k1KeyPairFromSeed(seed)
+		copy(priv[:], secret[:])
+	default:
+		err = pair.ErrUnknownKeyType
+	}
+
+	return pub, priv, err
+}
+
+// deriveKeyPair derives the key pair of a junction from the secret of its
+// parent
+func deriveKeyPair(keyType pair.KeyType, priv [64]byte, junction suri.Junction) ([]byte, [64]byte, error) {
+	if keyType == pair.Sr25519 {
+		var (
+			pub [32]byte
+			err error
+		)
+		if junction.IsHard {
+			pub, priv, err = crypto.Sr25519HardDerive(priv, junction.ChainCode)
+		} else {
+			pub, priv, err = crypto.Sr25519SoftDerive(priv, junction.ChainCode)
+		}
+
+		return pub[:], priv, err
+	}
+
+	if !junction.IsHard {
+		return nil, [64]byte{}, fmt.Errorf("%v does not support soft junctions", keyType)
+	}
+
+	// note: ed25519 and ecdsa derive from the seed, the first 32 bytes
+	var seed [32]byte
+	switch keyType {
+	case pair.Ed25519:
+		seed = crypto.Ed25519HardDerive(priv[:32], junction.ChainCode)
+	case pair.Ecdsa:
+		seed = crypto.Secp256k1HardDerive(priv[:32], junction.ChainCode)
+	default:
+		return nil, [64]byte{}, pair.ErrUnknownKeyType
+	}
+
+	return keyPairFromSeed(keyType, seed[:])
+}
@@ -1,9 +1,16 @@
 package keyring
 
 import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/tsfdsong/go-polkadot/common/crypto"
 	"github.com/tsfdsong/go-polkadot/common/keyring/address"
+	"github.com/tsfdsong/go-polkadot/common/keyring/pair"
+	"github.com/tsfdsong/go-polkadot/common/keyring/suri"
 	"github.com/tsfdsong/go-polkadot/common/u8util"
 )
 
@@ -18,15 +25,13 @@ func TestKeyRing(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = kr.AddFromSeed(seedOne, nil)
+	_, err = kr.AddFromSeed(seedOne, nil, pair.Ed25519)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	address.SetDefaultPrefix(address.FortyTwo)
-
 	t.Run("adds the pair", func(t *testing.T) {
-		p, err := kr.AddFromSeed(seedTwo, nil)
+		p, err := kr.AddFromSeed(seedTwo, nil, pair.Ed25519)
 		if err != nil {
 			t.Error(err)
 			return
@@ -49,9 +54,11 @@ func TestKeyRing(t *testing.T) {
 	})
 
 	t.Run("adds from a mnemonic", func(t *testing.T) {
-		address.SetDefaultPrefix(address.SixtyEight)
+		if err := kr.SetAddressPrefix(address.SixtyEight); err != nil {
+			t.Fatal(err)
+		}
 
-		p, err := kr.AddFromMnemonic("moral movie very draw assault whisper awful rebuild speed purity repeat card", "", nil)
+		p, err := kr.AddFromMnemonic("moral movie very draw assault whisper awful rebuild speed purity repeat card", "", nil, pair.Ed25519)
 		if err != nil {
 			t.Error(err)
 			return
@@ -63,13 +70,59 @@ func TestKeyRing(t *testing.T) {
 			return
 		}
 
-		expected := "7pDZKLEixRnF6Q5jzr7DsCEiNPt3d6Rknc14SyUcnRwTQK14"
+		expected := "cg7y6K6k7LQEJzagm9jrCudfx7tR3HyJ6zBdHG2zvQwpFqaFF"
+
+		if addr != expected {
+			t.Errorf("expected %s, received %s", expected, addr)
+		}
+	})
+
+	t.Run("adds from a mnemonic with the legacy derivation", func(t *testing.T) {
+		legacy, err := NewWithPrefix(address.SixtyEight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy.LegacyMnemonic = true
+
+		p, err := legacy.AddFromMnemonic("moral movie very draw assault whisper awful rebuild speed purity repeat card", "", nil, pair.Ed25519)
+		if err != nil {
+			t.Fatal(err)
+		}
 
+		addr, err := p.Address()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "cg4nmfvbcDc2P7bKTegA8DtqJ31RTxvKKQdpi5bXUTDRQ3wJy"
 		if addr != expected {
 			t.Errorf("expected %s, received %s", expected, addr)
 		}
 	})
 
+	t.Run("derives the development accounts from the development phrase", func(t *testing.T) {
+		for uri, expected := range map[string]string{
+			suri.DevPhrase:           "0x46ebddef8cd9bb167dc30878d7113b7e168e6f0646beffd77d69d39bad76b47a",
+			"//Alice":                "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d",
+			"//Alice//stash":         "0xbe5ddb1579b72e84524fc29e78609e3caf42e85aa118ebfe0b0ad404b5bdd25f",
+			suri.DevPhrase + "//Bob": "0x8eaf04151687736326c9fea17e25fc5287613693c912909cb226aa4794f26a48",
+		} {
+			p, err := kr.AddFromURI(uri, nil, pair.Sr25519)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pk, err := p.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(pk) != string(u8util.FromHex(expected)) {
+				t.Errorf("%s: expected %s, received %x", uri, expected, pk)
+			}
+		}
+	})
+
 	t.Run("allows publicKeys retrieval", func(t *testing.T) {
 		kr1, err := New()
 		if err != nil {
@@ -77,12 +130,12 @@ func TestKeyRing(t *testing.T) {
 			return
 		}
 
-		_, err = kr1.AddFromSeed(seedOne, nil)
+		_, err = kr1.AddFromSeed(seedOne, nil, pair.Ed25519)
 		if err != nil {
 			t.Fatal(err)
 			return
 		}
-		_, err = kr1.AddFromSeed(seedTwo, nil)
+		_, err = kr1.AddFromSeed(seedTwo, nil, pair.Ed25519)
 		if err != nil {
 			t.Error(err)
 			return
@@ -145,7 +198,7 @@ func TestKeyRing(t *testing.T) {
 	t.Run("allows adding from JSON", func(t *testing.T) {
 		expected := [32]byte{209, 114, 167, 76, 218, 76, 134, 89, 18, 195, 43, 160, 168, 10, 87, 174, 105, 171, 174, 65, 14, 92, 203, 89, 222, 232, 78, 47, 68, 50, 219, 79}
 
-		p, err := kr.AddFromJSON([]byte(`{"Address":"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDtZ","Encoded":"3053020101300506032b657004220420416c696365202020202020202020202020202020202020202020202020202020a123032100d172a74cda4c865912c32ba0a80a57ae69abae410e5ccb59dee84e2f4432db4f","Encoding":{"Content":"PKCS8","Type":"none","Version":"0"},"Meta":{"isTesting":true,"name":"alice"}}`), nil)
+		p, err := kr.AddFromJSON([]byte(`{"Address":"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQua","Encoded":"3053020101300506032b657004220420416c696365202020202020202020202020202020202020202020202020202020a123032100d172a74cda4c865912c32ba0a80a57ae69abae410e5ccb59dee84e2f4432db4f","Encoding":{"Content":"PKCS8","Type":"none","Version":"0"},"Meta":{"isTesting":true,"name":"alice"}}`), nil)
 		if err != nil {
 			t.Error(err)
 			return
@@ -169,7 +222,7 @@ func TestKeyRing(t *testing.T) {
 		}
 
 		password := "password"
-		p, err = kr.AddFromJSON([]byte(`{"Address":"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaDtZ","Encoded":"7accc3662c519b2def55141e805833876ba0222452b93c9f595fa208e1540631b288f58ebed5e4e7639f977cd4ecf4a5d7947339a71a50576eb6b4a49fab4e8777caf8a788f0051bfb123828430a953391c98b63fca6d925e0ccf0ceaa1824147b0baf26adf47b9a0f0494aad8eda61ee4ad2022be781a6b83d0c1139b","Encoding":{"Content":"PKCS8","Type":"xsalsa20-poly1305","Version":"0"},"Meta":{"isTesting":true,"name":"alice"}}`), &password)
+		p, err = kr.AddFromJSON([]byte(`{"Address":"5GoKvZWG5ZPYL1WUovuHW3zJBWBP5eT8CbqjdRY4Q6iMaQua","Encoded":"7accc3662c519b2def55141e805833876ba0222452b93c9f595fa208e1540631b288f58ebed5e4e7639f977cd4ecf4a5d7947339a71a50576eb6b4a49fab4e8777caf8a788f0051bfb123828430a953391c98b63fca6d925e0ccf0ceaa1824147b0baf26adf47b9a0f0494aad8eda61ee4ad2022be781a6b83d0c1139b","Encoding":{"Content":"PKCS8","Type":"xsalsa20-poly1305","Version":"0"},"Meta":{"isTesting":true,"name":"alice"}}`), &password)
 		if err != nil {
 			t.Error(err)
 			return
@@ -192,4 +245,289 @@ func TestKeyRing(t *testing.T) {
 			}
 		}
 	})
+	t.Run("adds an sr25519 pair", func(t *testing.T) {
+		p, err := kr.AddFromSeed(seedTwo, nil, pair.Sr25519)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if p.Type() != pair.Sr25519 {
+			t.Errorf("expected %v, received %v", pair.Sr25519, p.Type())
+		}
+
+		pk, err := p.PublicKey()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// NOTE: Substrate's sr25519 test vector for this seed
+		expected := u8util.FromHex("0x44a996beb1eef7bdcab976ab6d2ca26104834164ecf28fb375600576fcc6eb0f")
+		if string(pk[:]) != string(expected) {
+			t.Errorf("expected %x, received %x", expected, pk)
+		}
+
+		message := []byte("message")
+		sig, err := p.Sign(message)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ok, err := p.Verify(message, sig)
+		if err != nil || !ok {
+			t.Errorf("expected the signature to verify, %v", err)
+		}
+	})
+
+	t.Run("rejects an unknown key type", func(t *testing.T) {
+		if _, err := kr.AddFromSeed(seedTwo, nil, pair.KeyType(-1)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+	t.Run("adds an ecdsa pair", func(t *testing.T) {
+		p, err := kr.AddFromSeed(seedTwo, nil, pair.Ecdsa)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		pk, err := p.PublicKey()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// NOTE: Substrate's ecdsa test vector for this seed
+		expected := u8util.FromHex("0x028db55b05db86c0b1786ca49f095d76344c9e6056b2f02701a7e7f3c20aabfd91")
+		if string(pk) != string(expected) {
+			t.Errorf("expected %x, received %x", expected, pk)
+		}
+
+		addr, err := p.Address()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		found, err := kr.GetPair([]byte(addr))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if found != p {
+			t.Error("expected the pair to be found by its address")
+		}
+	})
+
+	t.Run("requires the public key of an ecdsa address", func(t *testing.T) {
+		_, err := kr.AddFromAddress([]byte("0x"+hex.EncodeToString(seedTwo)), nil, nil, pair.Ecdsa)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("derives pairs from a uri", func(t *testing.T) {
+		// NOTE: the well-known development accounts of subkey
+		for _, tt := range []struct {
+			uri      string
+			keyType  pair.KeyType
+			expected string
+		}{
+			{suri.DevSeed + "//Alice", pair.Ed25519, "0x88dc3417d5058ec4b4503e0c12ea1a0a89be200fe98922423d4334014fa6b0ee"},
+			{suri.DevSeed + "//Alice//stash", pair.Ed25519, "0x451781cd0c5504504f69ceec484cc66e4c22a2b6a9d20fb1a426d91ad074a2a8"},
+			{suri.DevSeed, pair.Sr25519, "0x46ebddef8cd9bb167dc30878d7113b7e168e6f0646beffd77d69d39bad76b47a"},
+			{suri.DevSeed + "//Alice", pair.Sr25519, "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"},
+			{suri.DevSeed + "//Alice//stash", pair.Sr25519, "0xbe5ddb1579b72e84524fc29e78609e3caf42e85aa118ebfe0b0ad404b5bdd25f"},
+			{suri.DevSeed + "//Alice", pair.Ecdsa, "0x020a1091341fe5664bfa1782d5e04779689068c916b04cb365ec3153755684d9a1"},
+		} {
+			t.Run(tt.keyType.String()+tt.uri[len(suri.DevSeed):], func(t *testing.T) {
+				p, err := kr.AddFromURI(tt.uri, nil, tt.keyType)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				pk, err := p.PublicKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				expected := u8util.FromHex(tt.expected)
+				if string(pk) != string(expected) {
+					t.Errorf("expected %x, received %x", expected, pk)
+				}
+			})
+		}
+	})
+
+	t.Run("derives the public key of a soft junction", func(t *testing.T) {
+		parent, err := kr.AddFromURI(suri.DevSeed+"//Alice", nil, pair.Sr25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := kr.AddFromURI(suri.DevSeed+"//Alice/soft", nil, pair.Sr25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parentKey, err := parent.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		childKey, err := child.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var pub [32]byte
+		copy(pub[:], parentKey)
+		derived, err := crypto.Sr25519SoftDerivePublic(pub, suri.NewJunction("soft", false).ChainCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(derived[:]) != string(childKey) {
+			t.Errorf("expected %x, received %x", derived, childKey)
+		}
+	})
+
+	t.Run("rejects soft junctions of ed25519 and ecdsa", func(t *testing.T) {
+		for _, keyType := range []pair.KeyType{pair.Ed25519, pair.Ecdsa} {
+			if _, err := kr.AddFromURI(suri.DevSeed+"/soft", nil, keyType); err == nil {
+				t.Errorf("expected an error for %s", keyType)
+			}
+		}
+	})
+
+	t.Run("keeps the prefix of each keyring", func(t *testing.T) {
+		alice := suri.DevPhrase + "//Alice"
+		expected := map[address.PrefixEnum]string{
+			address.Polkadot: "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5",
+			address.Kusama:   "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F",
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, len(expected))
+		for prefix, addr := range expected {
+			wg.Add(1)
+			go func(prefix address.PrefixEnum, addr string) {
+				defer wg.Done()
+
+				k, err := NewWithPrefix(prefix)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				p, err := k.AddFromURI(alice, nil, pair.Sr25519)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				received, err := p.Address()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if received != addr {
+					errs <- fmt.Errorf("expected %s, received %s", addr, received)
+					return
+				}
+
+				data, err := k.ToJSON([]byte(addr), nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !strings.Contains(string(data), addr) {
+					errs <- fmt.Errorf("expected the JSON to hold %s", addr)
+					return
+				}
+
+				if _, err := k.DecodeAddress([]byte(addr)); err != nil {
+					errs <- err
+				}
+			}(prefix, addr)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+
+		if address.DefaultPrefix != address.FortyTwo {
+			t.Errorf("expected the default prefix to be left alone, received %v", address.DefaultPrefix)
+		}
+	})
+
+	t.Run("decodes the addresses of its own prefix", func(t *testing.T) {
+		k, err := NewWithPrefix(address.Polkadot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := k.DecodeAddress([]byte("HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F")); err == nil {
+			t.Error("expected an error decoding a kusama address")
+		}
+	})
+
+	t.Run("updates the prefix of its pairs", func(t *testing.T) {
+		k, err := NewWithPrefix(address.Polkadot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := k.AddFromURI(suri.DevPhrase+"//Alice", nil, pair.Sr25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k.SetAddressPrefix(address.Kusama); err != nil {
+			t.Fatal(err)
+		}
+
+		addr, err := p.Address()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"
+		if addr != expected {
+			t.Errorf("expected %s, received %s", expected, addr)
+		}
+	})
+
+	t.Run("leaves the prefix of an added pair alone", func(t *testing.T) {
+		polkadot, err := NewWithPrefix(address.Polkadot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := polkadot.AddFromURI(suri.DevPhrase+"//Alice", nil, pair.Sr25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kusama, err := NewWithPrefix(address.Kusama)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, err := kusama.AddPair(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct {
+			pair     *pair.Pair
+			expected string
+		}{
+			{p, "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+			{added, "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"},
+		} {
+			addr, err := tt.pair.Address()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr != tt.expected {
+				t.Errorf("expected %s, received %s", tt.expected, addr)
+			}
+		}
+	})
 }
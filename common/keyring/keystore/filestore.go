@@ -0,0 +1,134 @@
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// NOTE: the values hold encrypted keys, only the owner may read them
+var (
+	directoryMode os.FileMode = 0700
+	fileMode      os.FileMode = 0600
+	tempPrefix                = ".tmp-"
+)
+
+// NewFileStore returns a store in directory, creating it when missing
+func NewFileStore(directory string) (*FileStore, error) {
+	if err := os.MkdirAll(directory, directoryMode); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		directory: directory,
+	}, nil
+}
+
+// All returns the values of all the keys
+func (f *FileStore) All() (map[string][]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := ioutil.ReadDir(f.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || validateKey(entry.Name()) != nil {
+			continue
+		}
+
+		value, err := ioutil.ReadFile(f.path(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		values[entry.Name()] = value
+	}
+
+	return values, nil
+}
+
+// Get ...
+func (f *FileStore) Get(key string) ([]byte, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	value, err := ioutil.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return value, err
+}
+
+// Remove ...
+func (f *FileStore) Remove(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Set writes value aside and renames it in place, so a crash never leaves a
+// partial value
+func (f *FileStore) Set(key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	temp, err := ioutil.TempFile(f.directory, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(value); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), fileMode); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), f.path(key))
+}
+
+// path ...
+func (f *FileStore) path(key string) string {
+	return fmt.Sprintf("%s/%s", f.directory, key)
+}
+
+// validateKey rejects the keys that aren't plain file names
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package keystore
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	location := t.TempDir()
+	store, err := NewFileStore(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sets and gets values", func(t *testing.T) {
+		if err := store.Set("0x01", []byte("one")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Set("0x02", []byte("two")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Set("0x01", []byte("uno")); err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := store.Get("0x01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "uno" {
+			t.Errorf("expected uno, received %s", value)
+		}
+
+		stat, err := os.Stat(location + "/0x01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Mode().Perm() != fileMode {
+			t.Errorf("expected mode %v, received %v", fileMode, stat.Mode().Perm())
+		}
+	})
+
+	t.Run("lists the values of another store of the directory", func(t *testing.T) {
+		reopened, err := NewFileStore(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values, err := reopened.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string][]byte{"0x01": []byte("uno"), "0x02": []byte("two")}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("expected %v, received %v", expected, values)
+		}
+	})
+
+	t.Run("removes values", func(t *testing.T) {
+		if err := store.Remove("0x02"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get("0x02"); err != ErrNotFound {
+			t.Errorf("expected %v, received %v", ErrNotFound, err)
+		}
+		if err := store.Remove("0x02"); err != nil {
+			t.Errorf("expected removing a missing key to succeed, received %v", err)
+		}
+	})
+
+	t.Run("rejects keys outside the directory", func(t *testing.T) {
+		for _, key := range []string{"", "../0x01", ".hidden", "a/b"} {
+			if err := store.Set(key, []byte("value")); err != ErrInvalidKey {
+				t.Errorf("%q: expected %v, received %v", key, ErrInvalidKey, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,9 @@
+package keystore
+
+// InterfaceKeyStore persists the JSON of the pairs of a keyring, by key
+type InterfaceKeyStore interface {
+	All() (map[string][]byte, error)
+	Get(key string) ([]byte, error)
+	Remove(key string) error
+	Set(key string, value []byte) error
+}
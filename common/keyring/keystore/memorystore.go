@@ -0,0 +1,52 @@
+package keystore
+
+// NewMemoryStore ...
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		values: make(map[string][]byte),
+	}
+}
+
+// All ...
+func (m *MemoryStore) All() (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	values := make(map[string][]byte, len(m.values))
+	for key, value := range m.values {
+		values[key] = append([]byte(nil), value...)
+	}
+
+	return values, nil
+}
+
+// Get ...
+func (m *MemoryStore) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Remove ...
+func (m *MemoryStore) Remove(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, key)
+	return nil
+}
+
+// Set ...
+func (m *MemoryStore) Set(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = append([]byte(nil), value...)
+	return nil
+}
@@ -0,0 +1,32 @@
+package keystore
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// note: ensure the struct(s) implement the interface(s) at compile time
+	_ InterfaceKeyStore = (*FileStore)(nil)
+	_ InterfaceKeyStore = (*MemoryStore)(nil)
+)
+
+var (
+	// ErrNotFound ...
+	ErrNotFound = errors.New("keystore: key not found")
+	// ErrInvalidKey ...
+	ErrInvalidKey = errors.New("keystore: invalid key")
+)
+
+// FileStore keeps each value in its own file of a directory, like the
+// FileStore of polkadot.js
+type FileStore struct {
+	directory string
+	mu        sync.RWMutex
+}
+
+// MemoryStore keeps the values in memory
+type MemoryStore struct {
+	values map[string][]byte
+	mu     sync.RWMutex
+}
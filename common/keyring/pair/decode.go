@@ -1,6 +1,7 @@
 package pair
 
 import (
+	"encoding/binary"
 	"errors"
 	"log"
 
@@ -8,65 +9,163 @@ import (
 	"github.com/tsfdsong/go-polkadot/common/u8util"
 )
 
-// Decode ...
-func Decode(passphrase *string, encrypted []byte) ([32]byte, [64]byte, error) {
-	var (
-		naclPub  [32]byte
-		naclPriv [64]byte
-	)
-
+// Decode decodes the PKCS8 encoding of a pair, decrypting it with the zero
+// padded passphrase of the legacy format when passphrase isn't nil
+func Decode(keyType KeyType, passphrase *string, encrypted []byte) ([]byte, [64]byte, error) {
 	if encrypted == nil || len(encrypted) == 0 {
-		return naclPub, naclPriv, errors.New("no encrypted data to decode")
+		return nil, [64]byte{}, errors.New("no encrypted data to decode")
 	}
 
 	encoded := encrypted
 	if passphrase != nil {
-		if len(encrypted) < 24 {
-			return naclPub, naclPriv, errors.New("encrypted length is less than 24")
-		}
-
 		secret := u8util.FixLength([]byte(*passphrase), 256, true)
+		defer zero(secret)
 		if len(secret) != 32 {
 			log.Println(secret, len(secret))
-			return naclPub, naclPriv, errors.New("secret length is not 32")
+			return nil, [64]byte{}, errors.New("secret length is not 32")
 		}
 
 		var (
 			tmpSecret [32]byte
-			tmpNonce  [24]byte
 			err       error
 		)
 		copy(tmpSecret[:], secret)
-		copy(tmpNonce[:], encrypted[0:24])
-		encoded, err = crypto.NaclDecrypt(encrypted[24:], tmpNonce, tmpSecret)
+		encoded, err = decrypt(encrypted, tmpSecret)
+		zero(tmpSecret[:])
 		if err != nil {
-			return naclPub, naclPriv, err
+			return nil, [64]byte{}, err
 		}
+
+		// note: the decrypted body is ours to wipe, not the caller's input
+		defer zero(encoded)
+	}
+
+	return decodePkcs8(keyType, encoded)
+}
+
+// DecodeScrypt decodes the PKCS8 encoding of a pair encrypted by
+// EncodeScrypt
+func DecodeScrypt(keyType KeyType, passphrase string, encrypted []byte) ([]byte, [64]byte, error) {
+	if len(encrypted) < scryptSaltLength+12 {
+		return nil, [64]byte{}, errors.New("encrypted length is less than the scrypt header")
+	}
+
+	salt := encrypted[0:scryptSaltLength]
+	header := encrypted[scryptSaltLength : scryptSaltLength+12]
+	params := &ScryptParams{
+		N: binary.LittleEndian.Uint32(header[0:4]),
+		P: binary.LittleEndian.Uint32(header[4:8]),
+		R: binary.LittleEndian.Uint32(header[8:12]),
+	}
+
+	secret, err := scryptSecret(passphrase, salt, params)
+	if err != nil {
+		return nil, [64]byte{}, err
+	}
+
+	encoded, err := decrypt(encrypted[scryptSaltLength+12:], secret)
+	zero(secret[:])
+	if err != nil {
+		return nil, [64]byte{}, err
+	}
+	defer zero(encoded)
+
+	return decodePkcs8(keyType, encoded)
+}
+
+// decrypt decrypts the nonce prefixed data encrypt returns
+func decrypt(encrypted []byte, secret [32]byte) ([]byte, error) {
+	if len(encrypted) < 24 {
+		return nil, errors.New("encrypted length is less than 24")
 	}
 
+	var nonce [24]byte
+	copy(nonce[:], encrypted[0:24])
+	return crypto.NaclDecrypt(encrypted[24:], nonce, secret)
+}
+
+// decodePkcs8 ...
+func decodePkcs8(keyType KeyType, encoded []byte) ([]byte, [64]byte, error) {
+	var (
+		naclPub  []byte
+		naclPriv [64]byte
+	)
+
 	if encoded == nil || len(encoded) == 0 {
 		return naclPub, naclPriv, errors.New("unable to decode")
 	}
 
-	// note: check encoded lengths?
+	// NOTE: polkadot.js writes 64 byte secrets, the seed followed by the public
+	// key for ed25519, and reads 32 byte ones too. Look for the divider after a
+	// 64 byte secret first, as its decodePair does.
+	divOffset := DEFAULT_SEED_OFFSET + 2*DEFAULT_KEY_LENGTH
+	if !hasPkcs8Divider(encoded, divOffset) {
+		divOffset = DEFAULT_SEED_OFFSET + DEFAULT_KEY_LENGTH
+		if !hasPkcs8Divider(encoded, divOffset) {
+			return naclPub, naclPriv, errors.New("Invalid Pkcs8 divider found in body")
+		}
+	}
+
+	publicLength := DEFAULT_KEY_LENGTH
+	if keyType == Ecdsa {
+		publicLength = DEFAULT_KEY_LENGTH + 1
+	}
+	publicOffset := divOffset + len(DEFAULT_PKCS8_DIVIDER)
+	if len(encoded) < publicOffset+publicLength {
+		return naclPub, naclPriv, errors.New("Pkcs8 body is too short")
+	}
+
 	header := encoded[0:DEFAULT_SEED_OFFSET]
-	divider := encoded[DEFAULT_DIV_OFFSET : DEFAULT_DIV_OFFSET+len(DEFAULT_PKCS8_DIVIDER)]
 	if string(header) != string(DEFAULT_PKCS8_HEADER) {
 		return naclPub, naclPriv, errors.New("Invalid Pkcs8 header found in body")
 	}
-	if string(divider) != string(DEFAULT_PKCS8_DIVIDER) {
-		return naclPub, naclPriv, errors.New("Invalid Pkcs8 divider found in body")
+
+	publicKey := encoded[publicOffset : publicOffset+publicLength]
+	seed := encoded[DEFAULT_SEED_OFFSET:divOffset]
+
+	// sr25519 secrets are always 64 bytes, ecdsa ones 32
+	switch {
+	case keyType == Sr25519 && len(seed) != 2*DEFAULT_KEY_LENGTH,
+		keyType == Ecdsa && len(seed) != DEFAULT_KEY_LENGTH:
+		return naclPub, naclPriv, errors.New("Invalid Pkcs8 secret length")
 	}
 
-	publicKey := encoded[DEFAULT_PUBLIC_OFFSET : DEFAULT_PUBLIC_OFFSET+DEFAULT_KEY_LENGTH]
-	seed := encoded[DEFAULT_SEED_OFFSET : DEFAULT_SEED_OFFSET+DEFAULT_KEY_LENGTH]
-	secretKey := u8util.Concat(seed, publicKey)
+	var (
+		pub       []byte
+		priv      [64]byte
+		secretKey []byte
+		err       error
+	)
+	switch keyType {
+	case Sr25519:
+		var exported [64]byte
+		copy(exported[:], seed)
+		priv = crypto.Sr25519FromEd25519Bytes(exported)
+		zero(exported[:])
+		secretKey = priv[:]
+
+		var key [32]byte
+		key, err = crypto.NewSr25519PublicKey(priv)
+		pub = key[:]
+	case Ecdsa:
+		copy(priv[:], seed)
+		secretKey = priv[:]
+		pub, err = crypto.NewSecp256k1PublicKey(ecdsaSecret(priv))
+	default:
+		secretKey = seed
+		if len(seed) == DEFAULT_KEY_LENGTH {
+			secretKey = u8util.Concat(seed, publicKey)
+			defer zero(secretKey)
+		}
 
-	pub, priv, err := crypto.NewNaclKeyPairFromSeed(seed)
+		var key [32]byte
+		key, priv, err = crypto.NewNaclKeyPairFromSeed(seed[:DEFAULT_KEY_LENGTH])
+		pub = key[:]
+	}
 	if err != nil {
 		return naclPub, naclPriv, err
 	}
-	if string(pub[:]) != string(publicKey) {
+	if string(pub) != string(publicKey) {
 		return naclPub, naclPriv, errors.New("Pkcs8 decoded publicKeys are not matching")
 	}
 	if string(priv[:]) != string(secretKey) {
@@ -77,3 +176,13 @@ func Decode(passphrase *string, encrypted []byte) ([32]byte, [64]byte, error) {
 	naclPriv = priv
 	return naclPub, naclPriv, nil
 }
+
+// hasPkcs8Divider returns true when the PKCS8 divider is at offset
+func hasPkcs8Divider(encoded []byte, offset int) bool {
+	end := offset + len(DEFAULT_PKCS8_DIVIDER)
+	if len(encoded) < end {
+		return false
+	}
+
+	return string(encoded[offset:end]) == string(DEFAULT_PKCS8_DIVIDER)
+}
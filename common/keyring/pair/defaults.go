@@ -1,5 +1,7 @@
 package pair
 
+import "errors"
+
 var (
 	// DEFAULT_PKCS8_DIVIDER ...
 	DEFAULT_PKCS8_DIVIDER = []byte{161, 35, 3, 33, 0}
@@ -14,3 +16,13 @@ var (
 	// DEFAULT_PUBLIC_OFFSET ...
 	DEFAULT_PUBLIC_OFFSET = DEFAULT_SEED_OFFSET + DEFAULT_KEY_LENGTH + len(DEFAULT_PKCS8_DIVIDER)
 )
+
+// jsonVersion is the version of the JSON ToJSON encodes
+var jsonVersion = "3"
+
+// ErrLocked ...
+var ErrLocked = errors.New("pair is locked")
+
+// ErrNotEncoded is returned when locking or unlocking a pair without an
+// encoded secret
+var ErrNotEncoded = errors.New("pair has no encoded secret to unlock from")
@@ -2,33 +2,150 @@ package pair
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 
 	"github.com/tsfdsong/go-polkadot/common/crypto"
 	"github.com/tsfdsong/go-polkadot/common/u8util"
+	"golang.org/x/crypto/scrypt"
 )
 
-// Encode ...
-func Encode(secretKey [64]byte, passphrase *string) ([]byte, error) {
-	encoded := u8util.Concat(DEFAULT_PKCS8_HEADER, secretKey[0:32], DEFAULT_PKCS8_DIVIDER, secretKey[32:64])
+// Encode returns the PKCS8 encoding of secretKey, encrypted with the zero
+// padded passphrase of the legacy format when passphrase isn't nil
+func Encode(keyType KeyType, secretKey [64]byte, passphrase *string) ([]byte, error) {
+	encoded, err := encodePkcs8(keyType, secretKey)
+	if err != nil {
+		return nil, err
+	}
 
 	if passphrase == nil {
 		return encoded, nil
 	}
 
+	secret := [32]byte{}
+	tmp := u8util.FixLength([]byte(*passphrase), 256, true)
+	copy(secret[:], tmp)
+	defer zero(secret[:])
+	defer zero(tmp)
+	defer zero(encoded)
+
+	return encrypt(encoded, secret)
+}
+
+// EncodeScrypt returns the PKCS8 encoding of secretKey encrypted as the
+// polkadot.js v3 JSON does, with a key derived from passphrase by scrypt. The
+// salt and the parameters prefix the nonce and the encrypted body.
+func EncodeScrypt(keyType KeyType, secretKey [64]byte, passphrase string, params *ScryptParams) ([]byte, error) {
+	if params == nil {
+		params = &DefaultScryptParams
+	}
+
+	encoded, err := encodePkcs8(keyType, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(encoded)
+
+	salt := make([]byte, scryptSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	secret, err := scryptSecret(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(secret[:])
+
+	encrypted, err := encrypt(encoded, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header[0:4], params.N)
+	binary.LittleEndian.PutUint32(header[4:8], params.P)
+	binary.LittleEndian.PutUint32(header[8:12], params.R)
+
+	return u8util.Concat(salt, header, encrypted), nil
+}
+
+// encodePkcs8 ...
+// note: ed25519 secrets are stored as seed and public key, sr25519 secrets as
+// the 64 byte secret in its ed25519 form followed by the public key and ecdsa
+// secrets as the 32 byte secret followed by the 33 byte compressed public key
+func encodePkcs8(keyType KeyType, secretKey [64]byte) ([]byte, error) {
+	switch keyType {
+	case Sr25519:
+		pub, err := crypto.NewSr25519PublicKey(secretKey)
+		if err != nil {
+			return nil, err
+		}
+
+		exported := crypto.Sr25519ToEd25519Bytes(secretKey)
+		defer zero(exported[:])
+
+		return u8util.Concat(DEFAULT_PKCS8_HEADER, exported[:], DEFAULT_PKCS8_DIVIDER, pub[:]), nil
+	case Ecdsa:
+		pub, err := crypto.NewSecp256k1PublicKey(ecdsaSecret(secretKey))
+		if err != nil {
+			return nil, err
+		}
+
+		return u8util.Concat(DEFAULT_PKCS8_HEADER, secretKey[0:32], DEFAULT_PKCS8_DIVIDER, pub), nil
+	default:
+		return u8util.Concat(DEFAULT_PKCS8_HEADER, secretKey[0:32], DEFAULT_PKCS8_DIVIDER, secretKey[32:64]), nil
+	}
+}
+
+// encrypt returns the nonce followed by the xsalsa20-poly1305 encrypted data
+func encrypt(data []byte, secret [32]byte) ([]byte, error) {
 	nonce := [24]byte{}
 	_, err := rand.Read(nonce[:])
 	if err != nil {
 		return nil, err
 	}
 
-	secret := [32]byte{}
-	tmp := u8util.FixLength([]byte(*passphrase), 256, true)
-	copy(secret[:], tmp)
-
-	encrypted, err := crypto.NaclEncrypt(encoded, nonce, secret)
+	encrypted, err := crypto.NaclEncrypt(data, nonce, secret)
 	if err != nil {
 		return nil, err
 	}
 
 	return u8util.Concat(nonce[:], encrypted), nil
 }
+
+// scryptSecret derives the encryption key of passphrase, the first 32 bytes of
+// the 64 scrypt returns
+func scryptSecret(passphrase string, salt []byte, params *ScryptParams) ([32]byte, error) {
+	var secret [32]byte
+
+	if err := params.validate(); err != nil {
+		return secret, err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, int(params.N), int(params.R), int(params.P), 64)
+	if err != nil {
+		return secret, err
+	}
+
+	copy(secret[:], key)
+	zero(key)
+
+	return secret, nil
+}
+
+// zero wipes secret material
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// isZero ...
+func isZero(b []byte) bool {
+	var acc byte
+	for i := range b {
+		acc |= b[i]
+	}
+
+	return acc == 0
+}
@@ -13,6 +13,8 @@ const (
 	XSalsa20_Poly1305 encodingTypeEnum = iota
 	// None ...
 	None
+	// Scrypt derives the encryption key, in the v3 JSON
+	Scrypt
 )
 
 // ErrUnknownEncodingType ...
@@ -33,6 +35,7 @@ func AllEncodingTypeEnums() []EncodingTypeEnum {
 	return []EncodingTypeEnum{
 		XSalsa20_Poly1305,
 		None,
+		Scrypt,
 	}
 }
 
@@ -43,6 +46,8 @@ func EncodingTypeEnumFromString(s string) (EncodingTypeEnum, error) {
 		return XSalsa20_Poly1305, nil
 	case "NONE":
 		return None, nil
+	case "SCRYPT":
+		return Scrypt, nil
 	default:
 		return nil, ErrUnknownEncodingType
 	}
@@ -56,6 +61,8 @@ func (e encodingTypeEnum) String() string {
 		return "xsalsa20-poly1305"
 	case None:
 		return "none"
+	case Scrypt:
+		return "scrypt"
 	default:
 		return ""
 	}
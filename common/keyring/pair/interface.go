@@ -1,22 +1,29 @@
 package pair
 
 import (
+	"github.com/tsfdsong/go-polkadot/common/keyring/address"
 	ktypes "github.com/tsfdsong/go-polkadot/common/keyring/types"
 )
 
 // InterfacePair ...
 type InterfacePair interface {
+	AccountID() ([]byte, error)
 	Address() (string, error)
+	AddressPrefix() address.PrefixEnum
 	DecodePkcs8(password *string, encoded []byte) error
 	EncodePkcs8(password *string) ([]byte, error)
 	GetMeta() (ktypes.Meta, error)
 	IsLocked() bool
 	Lock() error
-	PublicKey() ([32]byte, error)
+	OnLockChange(fn LockObserver) func()
+	PublicKey() ([]byte, error)
+	SetAddressPrefix(prefix address.PrefixEnum) error
 	SetMeta(meta ktypes.Meta) error
 	Sign(message []byte) ([]byte, error)
 	// note: change to Marshal?
 	ToJSON(password *string) ([]byte, error)
+	Type() KeyType
+	Unlock(passphrase string) error
 	Verify(message, signature []byte) (bool, error)
 }
 
@@ -0,0 +1,76 @@
+package pair
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyType is the signature scheme of a pair
+type KeyType int
+
+const (
+	// Ed25519 ...
+	Ed25519 KeyType = iota
+	// Sr25519 ...
+	Sr25519
+	// Ecdsa is secp256k1, with a 33 byte compressed public key
+	Ecdsa
+)
+
+// ErrUnknownKeyType ...
+var ErrUnknownKeyType = errors.New("key type: unknown")
+
+// AllKeyTypes returns all of the key types
+func AllKeyTypes() []KeyType {
+	return []KeyType{
+		Ed25519,
+		Sr25519,
+		Ecdsa,
+	}
+}
+
+// KeyTypeFromString ...
+func KeyTypeFromString(s string) (KeyType, error) {
+	switch strings.ToLower(s) {
+	case "ed25519":
+		return Ed25519, nil
+	case "sr25519":
+		return Sr25519, nil
+	case "ecdsa":
+		return Ecdsa, nil
+	default:
+		return 0, ErrUnknownKeyType
+	}
+}
+
+// String ...
+func (k KeyType) String() string {
+	switch k {
+	case Ed25519:
+		return "ed25519"
+	case Sr25519:
+		return "sr25519"
+	case Ecdsa:
+		return "ecdsa"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON ...
+func (k KeyType) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, k.String())), nil
+}
+
+// UnmarshalJSON ...
+func (k *KeyType) UnmarshalJSON(data []byte) error {
+	typ, err := KeyTypeFromString(strings.Replace(string(data), "\"", "", -1))
+	if err != nil {
+		return err
+	}
+
+	*k = typ
+
+	return nil
+}
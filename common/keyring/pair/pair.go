@@ -1,8 +1,10 @@
 package pair
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/tsfdsong/go-polkadot/common/crypto"
 	"github.com/tsfdsong/go-polkadot/common/keyring/address"
@@ -11,87 +13,356 @@ import (
 	"github.com/tsfdsong/go-polkadot/logger"
 )
 
-// NewPair ...
-func NewPair(naclPub [32]byte, naclPriv [64]byte, meta ktypes.Meta, defaultEncoded []byte) (*Pair, error) {
+// NewPair creates a pair of keyType from its public and secret key. Ecdsa
+// secrets take the first 32 bytes of the secret key.
+func NewPair(keyType KeyType, pub []byte, priv [64]byte, meta ktypes.Meta, defaultEncoded []byte) (*Pair, error) {
+	if keyType.String() == "" {
+		return nil, ErrUnknownKeyType
+	}
+
 	state := &State{
 		Meta:      meta,
-		PublicKey: naclPub,
+		PublicKey: append([]byte(nil), pub...),
+		Type:      keyType,
 	}
 
 	return &Pair{
 		State:          state,
 		defaultEncoded: defaultEncoded,
-		secretKey:      naclPriv,
+		secretKey:      priv,
+		locked:         isZero(priv[:]),
 	}, nil
 }
 
-// NewPairFromJSON ...
+// NewPairFromJSON decodes the JSON of ToJSON, of polkadot.js (version 2 and 3)
+// or of the legacy format
 func NewPairFromJSON(data []byte, password *string) (*Pair, error) {
-	tmp := forJSON{}
+	version := struct {
+		Encoding struct {
+			Version string
+		}
+	}{}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	switch version.Encoding.Version {
+	case "2", jsonVersion:
+		return newPairFromJSONV3(data, password)
+	default:
+		return newPairFromLegacyJSON(data, password)
+	}
+}
+
+// newPairFromJSONV3 ...
+func newPairFromJSONV3(data []byte, password *string) (*Pair, error) {
+	tmp := forJSONV3{}
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return nil, err
 	}
 
-	pubBytes, err := address.Decode(tmp.Address, nil)
+	// note: the content is the encoding and the key type, e.g. ["pkcs8", "sr25519"]
+	if len(tmp.Encoding.Content) != 2 || !strings.EqualFold(tmp.Encoding.Content[0], PKCS8.String()) {
+		return nil, ErrUnknownEncodingContent
+	}
+	keyType, err := KeyTypeFromString(tmp.Encoding.Content[1])
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := base64.StdEncoding.DecodeString(tmp.Encoded)
 	if err != nil {
 		return nil, err
 	}
+
+	var isScrypt, isEncrypted bool
+	for _, typ := range tmp.Encoding.Type {
+		switch typ {
+		case Scrypt:
+			isScrypt = true
+		case XSalsa20_Poly1305:
+			isEncrypted = true
+		}
+	}
+	if isEncrypted && password == nil {
+		return nil, errors.New("password required to decode the encrypted pair")
+	}
+
 	var (
-		pub  [32]byte
+		pub  []byte
 		priv [64]byte
 	)
-	copy(pub[:], pubBytes)
+	switch {
+	case isScrypt && isEncrypted:
+		pub, priv, err = DecodeScrypt(keyType, *password, encoded)
+	case isEncrypted:
+		pub, priv, err = Decode(keyType, password, encoded)
+	default:
+		pub, priv, err = Decode(keyType, nil, encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkJSONAddress(tmp.Address, keyType, pub); err != nil {
+		return nil, err
+	}
+
+	// note: the encrypted secret is kept, so the pair can lock and unlock
+	var defaultEncoded []byte
+	if isEncrypted {
+		defaultEncoded = encoded
+	}
+
+	pair, err := NewPair(keyType, pub, priv, tmp.Meta, defaultEncoded)
+	if err != nil {
+		return nil, err
+	}
+	pair.defaultScrypt = isScrypt && isEncrypted
+
+	return pair, nil
+}
+
+// newPairFromLegacyJSON ...
+func newPairFromLegacyJSON(data []byte, password *string) (*Pair, error) {
+	tmp := forJSON{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return nil, err
+	}
 
 	privBytes := u8util.FromHex(tmp.Encoded)
-	pub2, priv2, err := Decode(password, privBytes)
+	pub, priv, err := Decode(tmp.Type, password, privBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(pub2) != len(pub) {
-		return nil, errors.New("public keys do not match")
+	if err := checkJSONAddress(tmp.Address, tmp.Type, pub); err != nil {
+		return nil, err
+	}
+
+	// note: an unencrypted secret can't be unlocked from, Lock fails instead
+	var defaultEncoded []byte
+	if password != nil {
+		defaultEncoded = privBytes
+	}
+
+	return NewPair(tmp.Type, pub, priv, tmp.Meta, defaultEncoded)
+}
+
+// NewLockedPairFromJSON returns the locked pair of a JSON without decrypting
+// it, Unlock or UnlockFromJSON unlocks it
+func NewLockedPairFromJSON(data []byte) (*Pair, error) {
+	tmp := forJSONV3{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return nil, err
 	}
-	for idx := range pub2 {
-		if pub2[idx] != pub[idx] {
-			return nil, errors.New("public keys do not match")
+
+	var keyType KeyType
+	if len(tmp.Encoding.Content) == 2 {
+		typ, err := KeyTypeFromString(tmp.Encoding.Content[1])
+		if err != nil {
+			return nil, err
 		}
+		keyType = typ
+	} else {
+		legacy := forJSON{}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, err
+		}
+		keyType = legacy.Type
+	}
+
+	accountID, err := address.Decode(tmp.Address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := accountID
+	if keyType == Ecdsa {
+		pub = u8util.FromHex(tmp.PublicKey)
+		if string(toAccountID(keyType, pub)) != string(accountID) {
+			return nil, errors.New("ecdsa pairs require the public key of their address")
+		}
+	}
+
+	// note: kept so Unlock can decrypt the secret, the v3 JSON is scrypt
+	// encrypted, the legacy one hex encoded
+	var (
+		encoded []byte
+		isV3    = len(tmp.Encoding.Content) == 2
+	)
+	if isV3 {
+		encoded, err = base64.StdEncoding.DecodeString(tmp.Encoded)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		encoded = u8util.FromHex(tmp.Encoded)
+	}
+
+	pair, err := NewPair(keyType, pub, [64]byte{}, tmp.Meta, encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, typ := range tmp.Encoding.Type {
+		if typ == Scrypt {
+			pair.defaultScrypt = true
+		}
+	}
+
+	return pair, nil
+}
+
+// SetDefaultEncoding keeps the encrypted secret of data, a JSON of the pair,
+// as the encoding Unlock decrypts
+func (p *Pair) SetDefaultEncoding(data []byte) error {
+	if p.State == nil {
+		return errors.New("nil state")
+	}
+
+	decoded, err := NewLockedPairFromJSON(data)
+	if err != nil {
+		return err
+	}
+	if len(decoded.defaultEncoded) == 0 {
+		return ErrNotEncoded
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if decoded.State.Type != p.State.Type || string(decoded.State.PublicKey) != string(p.State.PublicKey) {
+		return errors.New("public keys do not match")
+	}
+
+	p.defaultEncoded = decoded.defaultEncoded
+	p.defaultScrypt = decoded.defaultScrypt
+	return nil
+}
+
+// UnlockFromJSON decrypts the secret of the pair from its JSON
+func (p *Pair) UnlockFromJSON(data []byte, passphrase *string) error {
+	if p.State == nil {
+		return errors.New("nil state")
+	}
+
+	decoded, err := NewPairFromJSON(data, passphrase)
+	if err != nil {
+		return err
+	}
+	if publicKey, _ := p.PublicKey(); decoded.State.Type != p.State.Type || string(decoded.State.PublicKey) != string(publicKey) {
+		return errors.New("public keys do not match")
+	}
+
+	p.setSecret(decoded.secretKey)
+	decoded.secretKey = [64]byte{}
+	return nil
+}
+
+// checkJSONAddress ...
+func checkJSONAddress(addr string, keyType KeyType, pub []byte) error {
+	accountID, err := address.Decode(addr, nil)
+	if err != nil {
+		return err
+	}
+
+	if string(toAccountID(keyType, pub)) != string(accountID) {
+		return errors.New("public keys do not match")
+	}
+
+	return nil
+}
+
+// AccountID returns the 32 byte account ID of the pair, the public key itself
+// except for ecdsa, where it is the blake2-256 of the public key
+func (p *Pair) AccountID() ([]byte, error) {
+	if p.State == nil {
+		return nil, errors.New("nil state")
 	}
-	copy(priv[:], priv2[:])
 
-	// TODO: nil defaultEncoded?
-	return NewPair(pub, priv, tmp.Meta, nil)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return toAccountID(p.State.Type, p.State.PublicKey), nil
 }
 
-// Address ...
+// Address returns the account ID of the pair encoded with its prefix
 func (p *Pair) Address() (string, error) {
+	accountID, err := p.AccountID()
+	if err != nil {
+		return "", err
+	}
+
+	return address.Encode(accountID, p.AddressPrefix())
+}
+
+// AddressPrefix ...
+func (p *Pair) AddressPrefix() address.PrefixEnum {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.prefix
+}
+
+// SetAddressPrefix sets the prefix Address and ToJSON encode with
+func (p *Pair) SetAddressPrefix(prefix address.PrefixEnum) error {
+	if prefix == nil {
+		return errors.New("nil prefix")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prefix = prefix
+	return nil
+}
+
+// EthereumAddress returns the 20 byte Ethereum address of an ecdsa pair
+func (p *Pair) EthereumAddress() ([20]byte, error) {
 	if p.State == nil {
-		return "", errors.New("nil state")
+		return [20]byte{}, errors.New("nil state")
+	}
+	if p.State.Type != Ecdsa {
+		return [20]byte{}, errors.New("only ecdsa pairs have an ethereum address")
 	}
 
-	return address.Encode(p.State.PublicKey[:], nil)
+	publicKey, _ := p.PublicKey()
+	return crypto.EthereumAddress(publicKey)
 }
 
 // DecodePkcs8 ...
 func (p *Pair) DecodePkcs8(passphrase *string, encoded []byte) error {
-	tmp := p.defaultEncoded
+	tmp, _ := p.defaultState()
 	if encoded != nil && len(encoded) > 0 {
 		tmp = encoded
 	}
 
-	pub, priv, err := Decode(passphrase, tmp)
+	pub, priv, err := Decode(p.State.Type, passphrase, tmp)
 	if err != nil {
 		return err
 	}
 
+	p.mu.Lock()
 	p.State.PublicKey = pub
-	p.secretKey = priv
+	p.mu.Unlock()
+
+	p.setSecret(priv)
+	zero(priv[:])
 
 	return nil
 }
 
 // EncodePkcs8 ...
 func (p *Pair) EncodePkcs8(passphrase *string) ([]byte, error) {
-	return Encode(p.secretKey, passphrase)
+	secret, locked := p.secretState()
+	defer zero(secret[:])
+
+	if locked {
+		return nil, ErrLocked
+	}
+
+	return Encode(p.State.Type, secret, passphrase)
 }
 
 // GetMeta ...
@@ -100,83 +371,243 @@ func (p *Pair) GetMeta() (ktypes.Meta, error) {
 		return nil, errors.New("nil state")
 	}
 
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	return p.State.Meta, nil
 }
 
 // IsLocked ...
 func (p *Pair) IsLocked() bool {
-	// note: string comparison is slow, better method? reflect.DeepEqual will probably also be slow...
-	blankSecret := [64]byte{}
-	return string(p.secretKey[:]) == string(blankSecret[:])
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.locked
 }
 
-// Lock ...
+// Lock wipes the secret key, failing for a pair without an encoded secret
+// to unlock from
 func (p *Pair) Lock() error {
-	p.secretKey = [64]byte{}
+	if encoded, _ := p.defaultState(); len(encoded) == 0 && !p.IsLocked() {
+		return ErrNotEncoded
+	}
+
+	p.setSecret([64]byte{})
+	return nil
+}
+
+// Unlock decrypts the secret key from the default encoding of the pair
+func (p *Pair) Unlock(passphrase string) error {
+	if p.State == nil {
+		return errors.New("nil state")
+	}
+	encoded, isScrypt := p.defaultState()
+	if len(encoded) == 0 {
+		return ErrNotEncoded
+	}
+
+	var (
+		pub  []byte
+		priv [64]byte
+		err  error
+	)
+	if isScrypt {
+		pub, priv, err = DecodeScrypt(p.State.Type, passphrase, encoded)
+	} else {
+		pub, priv, err = Decode(p.State.Type, &passphrase, encoded)
+	}
+	defer zero(priv[:])
+	if err != nil {
+		return err
+	}
+
+	if publicKey, _ := p.PublicKey(); string(pub) != string(publicKey) {
+		return errors.New("public keys do not match")
+	}
+
+	p.setSecret(priv)
 	return nil
 }
 
+// OnLockChange calls fn whenever the pair locks or unlocks, until the
+// returned function is called
+func (p *Pair) OnLockChange(fn LockObserver) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.observers == nil {
+		p.observers = make(map[int]LockObserver)
+	}
+
+	id := p.nextObserver
+	p.nextObserver++
+	p.observers[id] = fn
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		delete(p.observers, id)
+	}
+}
+
 // PublicKey ...
-func (p *Pair) PublicKey() ([32]byte, error) {
+func (p *Pair) PublicKey() ([]byte, error) {
 	if p.State == nil {
-		return [32]byte{}, errors.New("state is nil")
+		return nil, errors.New("state is nil")
 	}
 
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	return p.State.PublicKey, nil
 }
 
+// Copy returns a pair with the key, the secret, the meta and the prefix of
+// p, without its lock observers
+func (p *Pair) Copy() (*Pair, error) {
+	if p.State == nil {
+		return nil, errors.New("nil state")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var meta ktypes.Meta
+	if p.State.Meta != nil {
+		meta = make(ktypes.Meta, len(p.State.Meta))
+		for key, value := range p.State.Meta {
+			meta[key] = value
+		}
+	}
+
+	return &Pair{
+		State: &State{
+			Meta:      meta,
+			PublicKey: append([]byte(nil), p.State.PublicKey...),
+			Type:      p.State.Type,
+		},
+		defaultEncoded: append([]byte(nil), p.defaultEncoded...),
+		secretKey:      p.secretKey,
+		defaultScrypt:  p.defaultScrypt,
+		locked:         p.locked,
+		prefix:         p.prefix,
+	}, nil
+}
+
 // SetMeta ...
 func (p *Pair) SetMeta(meta ktypes.Meta) error {
 	if p.State == nil {
 		return errors.New("state is nil")
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.State.Meta = meta
 	return nil
 }
 
-// Sign ...
+// Sign signs message with the scheme of the pair
 func (p *Pair) Sign(message []byte) ([]byte, error) {
-	return crypto.NaclSign(p.secretKey, message)
+	if p.State == nil {
+		return nil, errors.New("nil state")
+	}
+
+	secret, locked := p.secretState()
+	defer zero(secret[:])
+
+	if locked {
+		return nil, ErrLocked
+	}
+
+	switch p.State.Type {
+	case Sr25519:
+		return crypto.Sr25519Sign(secret, message)
+	case Ecdsa:
+		key := ecdsaSecret(secret)
+		defer zero(key[:])
+
+		return crypto.Secp256k1Sign(key, message)
+	default:
+		return crypto.NaclSign(secret, message)
+	}
+}
+
+// Type ...
+func (p *Pair) Type() KeyType {
+	if p.State == nil {
+		return Ed25519
+	}
+
+	return p.State.Type
 }
 
-// ToJSON ...
+// ToJSON encodes the pair as the v3 JSON of polkadot.js, encrypted with a
+// scrypt derived key when passphrase isn't empty. The JSON of a locked pair
+// holds its default encoding as is, and no secret without one.
 func (p *Pair) ToJSON(passphrase *string) ([]byte, error) {
+	return p.ToJSONWithParams(passphrase, nil)
+}
+
+// ToJSONWithParams is ToJSON with the scrypt parameters, nil selecting
+// DefaultScryptParams
+func (p *Pair) ToJSONWithParams(passphrase *string, params *ScryptParams) ([]byte, error) {
 	if p.State == nil {
 		return nil, errors.New("nil state")
 	}
 
-	var isEncrypted bool
-	if passphrase != nil && *passphrase != "" {
-		isEncrypted = true
+	var (
+		encoded []byte
+		err     error
+	)
+	secret, locked := p.secretState()
+	defer zero(secret[:])
+
+	types := []encodingTypeEnum{None}
+	switch {
+	case locked:
+		// note: the secret is only known as it was loaded, encrypted the way
+		// Unlock decrypts it
+		var isScrypt bool
+		encoded, isScrypt = p.defaultState()
+		if isScrypt {
+			types = []encodingTypeEnum{Scrypt, XSalsa20_Poly1305}
+		} else if len(encoded) > 0 {
+			types = []encodingTypeEnum{XSalsa20_Poly1305}
+		}
+	case passphrase != nil && *passphrase != "":
+		types = []encodingTypeEnum{Scrypt, XSalsa20_Poly1305}
+		encoded, err = EncodeScrypt(p.State.Type, secret, *passphrase, params)
+	default:
+		encoded, err = Encode(p.State.Type, secret, nil)
+		defer zero(encoded)
 	}
-
-	encoded, err := Encode(p.secretKey, passphrase)
 	if err != nil {
 		logger.Errorf("err encoding secretkey\n%v", err)
 		return nil, err
 	}
 
-	addr, err := address.Encode(p.State.PublicKey[:], nil)
+	addr, err := p.Address()
 	if err != nil {
 		logger.Errorf("err encoding public key\n%v", err)
 		return nil, err
 	}
 
-	typ := None
-	if isEncrypted {
-		typ = XSalsa20_Poly1305
+	meta, _ := p.GetMeta()
+	publicKey, _ := p.PublicKey()
+	tmp := forJSONV3{
+		Address: addr,
+		Encoded: base64.StdEncoding.EncodeToString(encoded),
+		Encoding: encodingV3{
+			Content: []string{strings.ToLower(PKCS8.String()), p.State.Type.String()},
+			Type:    types,
+			Version: jsonVersion,
+		},
+		Meta: meta,
 	}
-	enc := encoding{
-		Content: PKCS8,
-		Type:    typ,
-		Version: "0",
-	}
-	tmp := forJSON{
-		Address:  addr,
-		Encoded:  u8util.ToHex(encoded, -1, false),
-		Encoding: enc,
-		Meta:     p.State.Meta,
+	if p.State.Type == Ecdsa {
+		tmp.PublicKey = u8util.ToHex(publicKey, -1, true)
 	}
 	return json.Marshal(tmp)
 }
@@ -193,5 +624,85 @@ func (p *Pair) Verify(message, signature []byte) (bool, error) {
 		return false, errors.New("nil state")
 	}
 
-	return crypto.NaclVerify(message, signature, p.State.PublicKey), nil
+	publicKey, _ := p.PublicKey()
+	switch p.State.Type {
+	case Sr25519:
+		return crypto.Sr25519Verify(message, signature, toKey32(publicKey)), nil
+	case Ecdsa:
+		return crypto.Secp256k1Verify(message, signature, publicKey), nil
+	default:
+		return crypto.NaclVerify(message, signature, toKey32(publicKey)), nil
+	}
+}
+
+// RecoverPublicKey returns the public key of the ecdsa pair that signed message
+func RecoverPublicKey(message, signature []byte) ([]byte, error) {
+	return crypto.Secp256k1Recover(message, signature)
+}
+
+// toAccountID ...
+func toAccountID(keyType KeyType, pub []byte) []byte {
+	if keyType == Ecdsa {
+		accountID := crypto.Secp256k1AccountID(pub)
+		return accountID[:]
+	}
+
+	return pub
+}
+
+// toKey32 ...
+func toKey32(pub []byte) [32]byte {
+	var key [32]byte
+	copy(key[:], pub)
+	return key
+}
+
+// secretState returns a copy of the secret key, which the caller wipes, and
+// whether the pair is locked
+func (p *Pair) secretState() ([64]byte, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.secretKey, p.locked
+}
+
+// defaultState returns the default encoding of the pair and whether it is
+// scrypt encrypted
+func (p *Pair) defaultState() ([]byte, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.defaultEncoded, p.defaultScrypt
+}
+
+// setSecret sets the secret key, a zero key locking the pair, and notifies
+// the observers of a change of state
+func (p *Pair) setSecret(secret [64]byte) {
+	p.mu.Lock()
+
+	p.secretKey = secret
+	locked := isZero(secret[:])
+	changed := locked != p.locked
+	p.locked = locked
+
+	var observers []LockObserver
+	if changed {
+		for _, fn := range p.observers {
+			observers = append(observers, fn)
+		}
+	}
+
+	p.mu.Unlock()
+
+	// note: called unlocked, so an observer can use the pair
+	for _, fn := range observers {
+		fn(p, locked)
+	}
+}
+
+// ecdsaSecret ...
+func ecdsaSecret(secretKey [64]byte) [32]byte {
+	var secret [32]byte
+	copy(secret[:], secretKey[:32])
+	return secret
 }
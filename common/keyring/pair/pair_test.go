@@ -1,9 +1,14 @@
 package pair
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/crypto"
+	"github.com/tsfdsong/go-polkadot/common/u8util"
 )
 
 func TestToFromJSON(t *testing.T) {
@@ -46,7 +51,7 @@ func TestToFromJSON(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			if !reflect.DeepEqual(p, tmpP) {
+			if !equalPairs(p, tmpP) {
 				t.Errorf("expected %v\nreceived %v", p, tmpP)
 			}
 		})
@@ -136,3 +141,494 @@ func TestPair(t *testing.T) {
 		}
 	})
 }
+
+func TestSr25519Pair(t *testing.T) {
+	password := "password"
+	pub, priv, err := crypto.NewSr25519KeyPairFromSeed(seeds["alice"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := NewPair(Sr25519, pub[:], priv, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("signs and verifies", func(t *testing.T) {
+		message := []byte{0x61, 0x62, 0x63, 0x64}
+		sig, err := alice.Sign(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ok, err := alice.Verify(message, sig)
+		if err != nil || !ok {
+			t.Errorf("expected the signature to verify, %v", err)
+		}
+
+		ok, err = alice.Verify([]byte{0x61, 0x62, 0x63, 0x64, 0x65}, sig)
+		if err != nil || ok {
+			t.Errorf("expected the signature not to verify, %v", err)
+		}
+	})
+
+	t.Run("encodes the secret and public key", func(t *testing.T) {
+		out, err := alice.EncodePkcs8(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(out) != len(DEFAULT_PKCS8_DIVIDER)+len(DEFAULT_PKCS8_HEADER)+96 {
+			t.Errorf("unexpected length %d", len(out))
+		}
+	})
+
+	for _, passphrase := range []*string{nil, &password} {
+		t.Run("keeps the type through JSON", func(t *testing.T) {
+			jsn, err := alice.ToJSON(passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := NewPairFromJSON(jsn, passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalPairs(alice, p) {
+				t.Errorf("expected %v\nreceived %v", alice, p)
+			}
+		})
+	}
+}
+
+func TestEcdsaPair(t *testing.T) {
+	password := "password"
+	pub, secret, err := crypto.NewSecp256k1KeyPairFromSeed(seeds["alice"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var priv [64]byte
+	copy(priv[:], secret[:])
+	alice, err := NewPair(Ecdsa, pub, priv, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte{0x61, 0x62, 0x63, 0x64}
+	sig, err := alice.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("signs and verifies", func(t *testing.T) {
+		ok, err := alice.Verify(message, sig)
+		if err != nil || !ok {
+			t.Errorf("expected the signature to verify, %v", err)
+		}
+
+		ok, err = alice.Verify([]byte{0x61, 0x62, 0x63, 0x64, 0x65}, sig)
+		if err != nil || ok {
+			t.Errorf("expected the signature not to verify, %v", err)
+		}
+	})
+
+	t.Run("recovers the public key", func(t *testing.T) {
+		recovered, err := RecoverPublicKey(message, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(recovered, pub) {
+			t.Errorf("expected %x\nreceived %x", pub, recovered)
+		}
+	})
+
+	t.Run("hashes the public key into the account ID", func(t *testing.T) {
+		accountID, err := alice.AccountID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := crypto.NewBlake2b256(pub)
+		if !reflect.DeepEqual(accountID, expected[:]) {
+			t.Errorf("expected %x\nreceived %x", expected, accountID)
+		}
+	})
+
+	t.Run("has an ethereum address", func(t *testing.T) {
+		addr, err := alice.EthereumAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := crypto.EthereumAddress(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != expected {
+			t.Errorf("expected %x\nreceived %x", expected, addr)
+		}
+	})
+
+	for _, passphrase := range []*string{nil, &password} {
+		t.Run("keeps the type through JSON", func(t *testing.T) {
+			jsn, err := alice.ToJSON(passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := NewPairFromJSON(jsn, passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalPairs(alice, p) {
+				t.Errorf("expected %v\nreceived %v", alice, p)
+			}
+		})
+	}
+}
+
+func TestJSONV3(t *testing.T) {
+	password := "password"
+	pMap, err := testKeyringPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := pMap["alice"]
+
+	t.Run("imports the unencrypted JSON of polkadot.js", func(t *testing.T) {
+		// NOTE: the root key of the development phrase, the secret in its ed25519 form
+		data := `{"encoded":"MFMCAQEwBQYDK2VwBCIEICiwriIca7BoVrKH9g1+oNmFUupaFtsWlWhJqjcds+tR/RkMznTfNWQytBC9ZGgjCdbe2yfHaEXa84hVfLrDyjShIwMhAEbr3e+M2bsWfcMIeNcRO34Wjm8GRr7/131p05utdrR6","encoding":{"content":["pkcs8","sr25519"],"type":["none"],"version":"3"},"address":"5DfhGyQdFobKM8NsWvEeAKk5EQQgYe9AydgJ7rMB6E1EqRzV","meta":{"name":"dev"}}`
+
+		p, err := NewPairFromJSON([]byte(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, secret, err := crypto.NewSr25519KeyPairFromSeed(u8util.FromHex("0xfac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Type() != Sr25519 || p.secretKey != secret {
+			t.Errorf("expected the sr25519 secret of the seed, received %v %x", p.Type(), p.secretKey)
+		}
+	})
+
+	t.Run("imports scrypt encrypted JSON", func(t *testing.T) {
+		// NOTE: encrypted as polkadot.js v3 does, with its default scrypt
+		// parameters, by an implementation independent of this package. The
+		// sr25519 body is the PKCS8 polkadot.js wrote for the development root
+		// key, the ed25519 one the RFC 8032 test 1 key with the 64 byte secret
+		// polkadot.js writes.
+		for i, tt := range []struct {
+			data    string
+			keyType KeyType
+			seed    string
+		}{
+			{`{"encoded":"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8AgAAAAQAAAAgAAAAAAQIDBAUGBwgJCgsMDQ4PEBESExQVFheg4Hmb8wtZuP4SAejSIJ+hCY8SesuEjF7cQDHenN3mi0GRU0XGX3xmvWUDsSP/yNm00ApDp4QBGoM7lQeAXCLvnkX6CbNxBYy8jH2mMmyDI4k8+PVOndAYiwgMD0glzHv8/8CvR9yRVCopZ/Rtu4ueAtKVLnTYyZc1BtJLHYwD8yIIpyfp","encoding":{"content":["pkcs8","sr25519"],"type":["scrypt","xsalsa20-poly1305"],"version":"3"},"address":"5DfhGyQdFobKM8NsWvEeAKk5EQQgYe9AydgJ7rMB6E1EqRzV","meta":{"name":"sr25519"}}`, Sr25519, "0xfac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e"},
+			{`{"encoded":"ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8AgAAAAQAAAAgAAAAYGRobHB0eHyAhIiMkJSYnKCkqKywtLi/rmStSzhQ5sYhW5ErwPQ0BicfhASyLftjk+7/lXjEsXweBskB1/NpT2HKhw/oNDAEl9/WRRDPy46Ud5LeI0DQOqtfBlExFQI3eBEF5EbxcBBGaHpIKoBrCPWqvCVPGV4Hl43rvLe0inY2FV9m6x5NP/Sa7PUxkO+1LK3Ea/Whr8GoVnH2d","encoding":{"content":["pkcs8","ed25519"],"type":["scrypt","xsalsa20-poly1305"],"version":"3"},"address":"5Gw54ghuAHodDGAS91DUxqvKa6PeT9bhDdns3ztBupY8pSyn","meta":{"name":"ed25519"}}`, Ed25519, "0x9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"},
+		} {
+			t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+				var secret [64]byte
+				if tt.keyType == Sr25519 {
+					_, secret, err = crypto.NewSr25519KeyPairFromSeed(u8util.FromHex(tt.seed))
+				} else {
+					_, secret, err = crypto.NewNaclKeyPairFromSeed(u8util.FromHex(tt.seed))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				p, err := NewPairFromJSON([]byte(tt.data), &password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p.Type() != tt.keyType || p.secretKey != secret {
+					t.Errorf("expected the %v secret of the seed, received %v %x", tt.keyType, p.Type(), p.secretKey)
+				}
+
+				wrong := "wrong"
+				if _, err := NewPairFromJSON([]byte(tt.data), &wrong); err == nil {
+					t.Error("expected an error")
+				}
+			})
+		}
+	})
+
+	t.Run("exports the v3 encoding", func(t *testing.T) {
+		jsn, err := alice.ToJSON(&password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmp := struct {
+			Encoded  string `json:"encoded"`
+			Encoding struct {
+				Content []string `json:"content"`
+				Type    []string `json:"type"`
+				Version string   `json:"version"`
+			} `json:"encoding"`
+		}{}
+		if err := json.Unmarshal(jsn, &tmp); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(tmp.Encoding.Content, []string{"pkcs8", "ed25519"}) {
+			t.Errorf("unexpected content %v", tmp.Encoding.Content)
+		}
+		if !reflect.DeepEqual(tmp.Encoding.Type, []string{"scrypt", "xsalsa20-poly1305"}) {
+			t.Errorf("unexpected type %v", tmp.Encoding.Type)
+		}
+		if tmp.Encoding.Version != "3" {
+			t.Errorf("unexpected version %v", tmp.Encoding.Version)
+		}
+
+		encoded, err := base64.StdEncoding.DecodeString(tmp.Encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// NOTE: salt, N, p and r, then the nonce and the encrypted PKCS8
+		expected := []byte{0, 128, 0, 0, 1, 0, 0, 0, 8, 0, 0, 0}
+		if !reflect.DeepEqual(encoded[32:44], expected) {
+			t.Errorf("expected the scrypt parameters %v, received %v", expected, encoded[32:44])
+		}
+		if len(encoded) != 32+12+24+16+DEFAULT_PUBLIC_OFFSET+DEFAULT_KEY_LENGTH {
+			t.Errorf("unexpected encoded length %d", len(encoded))
+		}
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		jsn, err := alice.ToJSONWithParams(&password, &ScryptParams{N: 1 << 10, P: 1, R: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wrong := "wrong"
+		if _, err := NewPairFromJSON(jsn, &wrong); err == nil {
+			t.Error("expected an error")
+		}
+		if _, err := NewPairFromJSON(jsn, nil); err == nil {
+			t.Error("expected an error")
+		}
+
+		p, err := NewPairFromJSON(jsn, &password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalPairs(alice, p) {
+			t.Errorf("expected %v\nreceived %v", alice, p)
+		}
+	})
+
+	t.Run("rejects unbounded scrypt parameters", func(t *testing.T) {
+		for _, params := range []*ScryptParams{
+			{N: 1 << 24, P: 1, R: 8},
+			// NOTE: 4 GiB, and 16 times the default work
+			{N: 1 << 20, P: 1, R: 32},
+			{N: 1 << 15, P: 16, R: 8},
+			{N: 1 << 31, P: 1 << 31, R: 1 << 31},
+			{N: 3, P: 1, R: 8},
+		} {
+			if _, err := EncodeScrypt(Ed25519, alice.secretKey, password, params); err != ErrInvalidScryptParams {
+				t.Errorf("expected %v for %v, received %v", ErrInvalidScryptParams, params, err)
+			}
+		}
+	})
+
+	t.Run("reads the legacy format", func(t *testing.T) {
+		expected, secret, err := crypto.NewSr25519KeyPairFromSeed(seeds["bob"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := Encode(Sr25519, secret, &password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pub, priv, err := Decode(Sr25519, &password, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pub, expected[:]) || priv != secret {
+			t.Errorf("unexpected pair %x %x", pub, priv)
+		}
+	})
+}
+
+func TestLock(t *testing.T) {
+	password := "password"
+	pub, priv, err := crypto.NewSr25519KeyPairFromSeed(seeds["alice"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := NewPair(Sr25519, pub[:], priv, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsn, err := alice.ToJSONWithParams(&password, &ScryptParams{N: 1 << 10, P: 1, R: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("refuses to sign when locked", func(t *testing.T) {
+		p, err := NewLockedPairFromJSON(jsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !p.IsLocked() {
+			t.Error("expected the pair to be locked")
+		}
+		if _, err := p.Sign([]byte{0x61}); err != ErrLocked {
+			t.Errorf("expected %v, received %v", ErrLocked, err)
+		}
+		if _, err := p.EncodePkcs8(nil); err != ErrLocked {
+			t.Errorf("expected %v, received %v", ErrLocked, err)
+		}
+	})
+
+	t.Run("unlocks with the passphrase", func(t *testing.T) {
+		p, err := NewLockedPairFromJSON(jsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Unlock("wrong"); err == nil {
+			t.Error("expected an error")
+		}
+		if !p.IsLocked() {
+			t.Error("expected the pair to stay locked")
+		}
+
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+		if p.IsLocked() || p.secretKey != priv {
+			t.Errorf("expected the secret of alice, received %x", p.secretKey)
+		}
+
+		message := []byte{0x61, 0x62, 0x63, 0x64}
+		sig, err := p.Sign(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := alice.Verify(message, sig)
+		if err != nil || !ok {
+			t.Errorf("expected the signature to verify, %v", err)
+		}
+	})
+
+	t.Run("unlocks the legacy encoding", func(t *testing.T) {
+		encoded, err := Encode(Sr25519, priv, &password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := NewPair(Sr25519, pub[:], [64]byte{}, nil, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+		if p.secretKey != priv {
+			t.Errorf("expected the secret of alice, received %x", p.secretKey)
+		}
+	})
+
+	t.Run("wipes the secret when locking", func(t *testing.T) {
+		p, err := NewPairFromJSON(jsn, &password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		if !p.IsLocked() || p.secretKey != [64]byte{} {
+			t.Errorf("expected a zero secret, received %x", p.secretKey)
+		}
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+		if p.secretKey != priv {
+			t.Errorf("expected the secret of alice, received %x", p.secretKey)
+		}
+	})
+
+	t.Run("refuses to lock without an encoded secret", func(t *testing.T) {
+		p, err := NewPair(Sr25519, pub[:], priv, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Lock(); err != ErrNotEncoded {
+			t.Errorf("expected %v, received %v", ErrNotEncoded, err)
+		}
+		if p.IsLocked() || p.secretKey != priv {
+			t.Error("expected the secret to be kept")
+		}
+
+		if err := p.SetDefaultEncoding(jsn); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("notifies the lock observers", func(t *testing.T) {
+		p, err := NewLockedPairFromJSON(jsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var states []bool
+		unsubscribe := p.OnLockChange(func(pair *Pair, locked bool) {
+			if pair != p {
+				t.Error("expected the observed pair")
+			}
+			states = append(states, locked)
+		})
+
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+		// note: no change of state
+		if err := p.UnlockFromJSON(jsn, &password); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Lock(); err != nil {
+			t.Fatal(err)
+		}
+
+		unsubscribe()
+		if err := p.Unlock(password); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []bool{false, true}
+		if !reflect.DeepEqual(states, expected) {
+			t.Errorf("expected %v, received %v", expected, states)
+		}
+	})
+}
+
+// equalPairs compares a pair decoded from JSON to the pair encoded, the
+// decoded pair keeps the encrypted secret of the JSON too
+func equalPairs(expected, received *Pair) bool {
+	return reflect.DeepEqual(expected.State, received.State) &&
+		expected.secretKey == received.secretKey &&
+		expected.locked == received.locked &&
+		expected.prefix == received.prefix
+}
@@ -26,7 +26,15 @@ func (p *Pairs) Add(pair *Pair) (*Pair, error) {
 		return nil, errors.New("nil pair map")
 	}
 
-	p.PairMap[string(pair.State.PublicKey[:])] = pair
+	accountID, err := pair.AccountID()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.PairMap[string(accountID)] = pair
 
 	return pair, nil
 }
@@ -38,6 +46,9 @@ func (p *Pairs) All() ([]*Pair, error) {
 		return nil, errors.New("nil pair map")
 	}
 
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	var pairs []*Pair
 	for k := range p.PairMap {
 		pairs = append(pairs, p.PairMap[k])
@@ -58,6 +69,9 @@ func (p *Pairs) Get(addr []byte) (*Pair, error) {
 		return nil, err
 	}
 
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	pair, ok := p.PairMap[string(decoded)]
 	if !ok {
 		// note: or just return nil, nil?
@@ -79,6 +93,9 @@ func (p *Pairs) Remove(addr []byte) error {
 		return err
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	delete(p.PairMap, string(decoded))
 
 	return nil
@@ -0,0 +1,42 @@
+package pair
+
+import "errors"
+
+// ScryptParams are the cost parameters of the scrypt key derivation
+type ScryptParams struct {
+	N uint32
+	P uint32
+	R uint32
+}
+
+var (
+	// DefaultScryptParams are the parameters of polkadot.js, 32 MiB of work
+	DefaultScryptParams = ScryptParams{N: 1 << 15, P: 1, R: 8}
+	// MaxScryptMemory bounds 128·N·r·p, the memory and work an imported JSON
+	// can make a derivation use
+	MaxScryptMemory uint64 = 256 << 20
+)
+
+var scryptSaltLength = 32
+
+// ErrInvalidScryptParams ...
+var ErrInvalidScryptParams = errors.New("invalid scrypt parameters")
+
+// validate ...
+func (s *ScryptParams) validate() error {
+	if s.N < 2 || s.N&(s.N-1) != 0 || s.P == 0 || s.R == 0 {
+		return ErrInvalidScryptParams
+	}
+
+	// note: each factor is below 2^32, bound them one at a time so the
+	// product can't overflow
+	budget := MaxScryptMemory / 128
+	for _, factor := range []uint32{s.N, s.R, s.P} {
+		if uint64(factor) > budget {
+			return ErrInvalidScryptParams
+		}
+		budget /= uint64(factor)
+	}
+
+	return nil
+}
@@ -58,7 +58,7 @@ func testKeyringPairs() (MapPair, error) {
 			return nil, err
 		}
 
-		pair, err := NewPair(pub, priv, meta, nil)
+		pair, err := NewPair(Ed25519, pub[:], priv, meta, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,9 @@
 package pair
 
 import (
+	"sync"
+
+	"github.com/tsfdsong/go-polkadot/common/keyring/address"
 	ktypes "github.com/tsfdsong/go-polkadot/common/keyring/types"
 )
 
@@ -13,7 +16,8 @@ var (
 // State ...
 type State struct {
 	Meta      ktypes.Meta
-	PublicKey [32]byte
+	PublicKey []byte
+	Type      KeyType
 }
 
 // Pair ...
@@ -21,13 +25,42 @@ type Pair struct {
 	State          *State
 	defaultEncoded []byte
 	secretKey      [64]byte
+	// note: defaultEncoded of a locked JSON is scrypt encrypted
+	defaultScrypt bool
+	locked        bool
+	observers     map[int]LockObserver
+	nextObserver  int
+	// note: nil encodes with address.DefaultPrefix
+	prefix address.PrefixEnum
+	mu     sync.RWMutex
 }
 
+// LockObserver is called with the new state of a pair that locks or unlocks
+type LockObserver func(p *Pair, locked bool)
+
 type forJSON struct {
 	Address  string
 	Encoded  string
 	Encoding encoding
 	Meta     ktypes.Meta
+	Type     KeyType
+}
+
+// forJSONV3 is the JSON of polkadot.js, from version 2 on
+type forJSONV3 struct {
+	Address  string      `json:"address"`
+	Encoded  string      `json:"encoded"`
+	Encoding encodingV3  `json:"encoding"`
+	Meta     ktypes.Meta `json:"meta"`
+	// note: the address of an ecdsa pair is the hash of its public key, the
+	// key is kept so the pair can be loaded locked
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+type encodingV3 struct {
+	Content []string           `json:"content"`
+	Type    []encodingTypeEnum `json:"type"`
+	Version string             `json:"version"`
 }
 
 type encoding struct {
@@ -39,6 +72,7 @@ type encoding struct {
 // Pairs ...
 type Pairs struct {
 	PairMap MapPair
+	mu      sync.RWMutex
 }
 
 // MapPair ...
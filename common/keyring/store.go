@@ -0,0 +1,213 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tsfdsong/go-polkadot/common/keyring/address"
+	"github.com/tsfdsong/go-polkadot/common/keyring/keystore"
+	"github.com/tsfdsong/go-polkadot/common/keyring/pair"
+	keytypes "github.com/tsfdsong/go-polkadot/common/keyring/types"
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+// ErrNoStore ...
+var ErrNoStore = errors.New("keyring has no store")
+
+// SetMeta sets the meta of a pair, updating its stored JSON
+func (k *KeyRing) SetMeta(addr []byte, meta keytypes.Meta) error {
+	p, err := k.GetPair(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := p.SetMeta(meta); err != nil {
+		return err
+	}
+
+	if k.store == nil {
+		return nil
+	}
+
+	key, err := storeKey(p)
+	if err != nil {
+		return err
+	}
+
+	data, err := k.store.Get(key)
+	if err == keystore.ErrNotFound {
+		return k.persist(p)
+	} else if err != nil {
+		return err
+	}
+
+	// note: only the meta changes, the stored secret isn't encrypted again
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	encodedMeta, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	stored["meta"] = encodedMeta
+
+	data, err = json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	return k.store.Set(key, data)
+}
+
+// Unlock decrypts the secret of a stored pair, which locks again after the
+// lock timeout of the keyring
+func (k *KeyRing) Unlock(addr []byte, password string) (*pair.Pair, error) {
+	if k.store == nil {
+		return nil, ErrNoStore
+	}
+
+	p, err := k.GetPair(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := storeKey(p)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := k.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.UnlockFromJSON(data, &password); err != nil {
+		return nil, err
+	}
+
+	k.scheduleLock(key, p)
+	return p, nil
+}
+
+// load adds the pairs of the store, locked
+func (k *KeyRing) load() error {
+	if k.store == nil {
+		return nil
+	}
+
+	values, err := k.store.All()
+	if err != nil {
+		return err
+	}
+
+	for key := range values {
+		p, err := pair.NewLockedPairFromJSON(values[key])
+		if err != nil {
+			return err
+		}
+
+		if err := p.SetAddressPrefix(k.prefix); err != nil {
+			return err
+		}
+
+		if _, err := k.Pairs.Add(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// persist writes the encrypted JSON of a pair to the store. A locked pair
+// doesn't replace the stored JSON, which holds its secret, and is otherwise
+// written with the encoding it was added with.
+func (k *KeyRing) persist(p *pair.Pair) error {
+	if k.store == nil {
+		return nil
+	}
+
+	key, err := storeKey(p)
+	if err != nil {
+		return err
+	}
+
+	locked := p.IsLocked()
+	if locked {
+		if _, err := k.store.Get(key); err == nil {
+			return nil
+		}
+	}
+
+	data, err := p.ToJSON(&k.password)
+	if err != nil {
+		return err
+	}
+
+	if err := k.store.Set(key, data); err != nil {
+		return err
+	}
+
+	if !locked {
+		// note: the stored secret is the one the pair unlocks from
+		if err := p.SetDefaultEncoding(data); err != nil {
+			return err
+		}
+		k.scheduleLock(key, p)
+	}
+
+	return nil
+}
+
+// unpersist removes a pair from the store
+func (k *KeyRing) unpersist(addr []byte) error {
+	if k.store == nil {
+		return nil
+	}
+
+	accountID, err := address.Decode(string(addr), nil)
+	if err != nil {
+		return err
+	}
+
+	key := u8util.ToHex(accountID, -1, true)
+
+	k.timersMu.Lock()
+	if timer, ok := k.lockTimers[key]; ok {
+		timer.Stop()
+		delete(k.lockTimers, key)
+	}
+	k.timersMu.Unlock()
+
+	return k.store.Remove(key)
+}
+
+// scheduleLock locks p after the lock timeout, replacing an earlier timer
+func (k *KeyRing) scheduleLock(key string, p *pair.Pair) {
+	if k.lockTimeout <= 0 {
+		return
+	}
+
+	k.timersMu.Lock()
+	defer k.timersMu.Unlock()
+
+	if timer, ok := k.lockTimers[key]; ok {
+		timer.Stop()
+	}
+
+	k.lockTimers[key] = time.AfterFunc(k.lockTimeout, func() {
+		p.Lock()
+	})
+}
+
+// storeKey is the hex encoded account ID of a pair
+func storeKey(p *pair.Pair) (string, error) {
+	accountID, err := p.AccountID()
+	if err != nil {
+		return "", err
+	}
+
+	return u8util.ToHex(accountID, -1, true), nil
+}
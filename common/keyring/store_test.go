@@ -0,0 +1,269 @@
+package keyring
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsfdsong/go-polkadot/common/keyring/address"
+	"github.com/tsfdsong/go-polkadot/common/keyring/keystore"
+	"github.com/tsfdsong/go-polkadot/common/keyring/pair"
+	"github.com/tsfdsong/go-polkadot/common/keyring/suri"
+	keytypes "github.com/tsfdsong/go-polkadot/common/keyring/types"
+)
+
+func TestKeyRingStore(t *testing.T) {
+	location := t.TempDir()
+	store, err := keystore.NewFileStore(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &Options{Prefix: address.Substrate, Store: store, Password: "password"}
+
+	alice := "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	message := []byte("this is a message")
+
+	t.Run("requires a password", func(t *testing.T) {
+		if _, err := NewWithOptions(&Options{Store: store}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("writes the added pairs encrypted", func(t *testing.T) {
+		kr, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := kr.AddFromURI(suri.DevSeed+"//Alice", keytypes.Meta{"name": "alice"}, pair.Sr25519); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := kr.AddFromURI(suri.DevSeed+"//Bob", nil, pair.Ecdsa); err != nil {
+			t.Fatal(err)
+		}
+
+		values, err := store.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 2 {
+			t.Fatalf("expected 2 stored pairs, received %d", len(values))
+		}
+		for key, value := range values {
+			if !strings.Contains(string(value), `"scrypt","xsalsa20-poly1305"`) {
+				t.Errorf("expected %s to be encrypted, received %s", key, value)
+			}
+		}
+	})
+
+	t.Run("loads the pairs locked", func(t *testing.T) {
+		kr, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pairs, err := kr.GetPairs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pairs) != 2 {
+			t.Fatalf("expected 2 pairs, received %d", len(pairs))
+		}
+		for _, p := range pairs {
+			if !p.IsLocked() {
+				t.Errorf("expected %v to be locked", p.Type())
+			}
+		}
+
+		p, err := kr.GetPair([]byte(alice))
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := p.GetMeta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta["name"] != "alice" {
+			t.Errorf("expected the meta to be loaded, received %v", meta)
+		}
+	})
+
+	t.Run("unlocks on demand", func(t *testing.T) {
+		kr, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := kr.Unlock([]byte(alice), "wrong"); err == nil {
+			t.Error("expected an error")
+		}
+
+		p, err := kr.Unlock([]byte(alice), "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.IsLocked() {
+			t.Fatal("expected the pair to be unlocked")
+		}
+
+		sig, err := p.Sign(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := p.Verify(message, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Error("expected the signature to verify")
+		}
+	})
+
+	t.Run("writes the meta", func(t *testing.T) {
+		kr, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kr.SetMeta([]byte(alice), keytypes.Meta{"name": "renamed"}); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := reloaded.Unlock([]byte(alice), "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := p.GetMeta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta["name"] != "renamed" {
+			t.Errorf("expected the meta to be written, received %v", meta)
+		}
+	})
+
+	t.Run("locks after the timeout", func(t *testing.T) {
+		timed := *options
+		timed.LockTimeout = 10 * time.Millisecond
+
+		kr, err := NewWithOptions(&timed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := kr.Unlock([]byte(alice), "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for !p.IsLocked() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !p.IsLocked() {
+			t.Error("expected the pair to lock")
+		}
+	})
+
+	t.Run("locks the added pairs after the timeout", func(t *testing.T) {
+		kr, err := NewWithOptions(&Options{Store: keystore.NewMemoryStore(), Password: "password", LockTimeout: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		added := make(chan *pair.Pair, 1)
+		go func() {
+			p, err := kr.AddFromURI(suri.DevSeed+"//Alice", nil, pair.Sr25519)
+			if err != nil {
+				t.Error(err)
+			}
+			added <- p
+		}()
+
+		var p *pair.Pair
+		select {
+		case p = <-added:
+		case <-time.After(5 * time.Second):
+			t.Fatal("AddFromURI did not return")
+		}
+		if p == nil {
+			t.FailNow()
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for !p.IsLocked() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !p.IsLocked() {
+			t.Error("expected the pair to lock")
+		}
+	})
+
+	t.Run("writes the encoded secret of locked pairs", func(t *testing.T) {
+		plain, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := plain.AddFromURI(suri.DevSeed+"//Alice", nil, pair.Sr25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		passphrase := "passphrase"
+		jsn, err := p.ToJSON(&passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		locked, err := pair.NewLockedPairFromJSON(jsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lockedOptions := &Options{Store: keystore.NewMemoryStore(), Password: "password"}
+		kr, err := NewWithOptions(lockedOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := kr.AddPair(locked); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded, err := NewWithOptions(lockedOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unlocked, err := reloaded.Unlock([]byte(alice), passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := unlocked.Sign(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := p.Verify(message, sig)
+		if err != nil || !ok {
+			t.Errorf("expected the signature to verify, %v", err)
+		}
+	})
+
+	t.Run("removes the pairs", func(t *testing.T) {
+		kr, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kr.RemovePair([]byte(alice)); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded, err := NewWithOptions(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reloaded.GetPair([]byte(alice)); err == nil {
+			t.Error("expected the pair to be removed")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package suri
+
+import (
+	"errors"
+	"math/big"
+	"regexp"
+	"strconv"
+
+	"github.com/tsfdsong/go-polkadot/common/crypto"
+	"github.com/tsfdsong/go-polkadot/common/u8compact"
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+// DevPhrase is the phrase of the publicly known development accounts, used
+// when a SURI has no phrase
+var DevPhrase = "bottom drive obey lake curtain smoke basket hold race lonely fit walk"
+
+// DevSeed is the seed of DevPhrase
+var DevSeed = "0xfac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e"
+
+// ErrInvalidSURI ...
+var ErrInvalidSURI = errors.New("invalid secret uri")
+
+// NOTE: phrase, junctions and password, e.g. "<mnemonic>//hard/soft///password"
+var suriRe = regexp.MustCompile(`^([\w ]+)?((?://?[^/]+)*)(?:///(.*))?$`)
+var junctionRe = regexp.MustCompile(`/(/?)([^/]+)`)
+
+// junctionIDLength is the length of a chain code
+var junctionIDLength = 32
+
+// Junction is a step of a derivation path, "//" marking a hard junction and
+// "/" a soft one
+type Junction struct {
+	ChainCode [32]byte
+	IsHard    bool
+}
+
+// SURI is a parsed secret URI
+type SURI struct {
+	// Phrase is a mnemonic or a 0x prefixed hex seed
+	Phrase   string
+	Path     []Junction
+	Password *string
+}
+
+// Parse splits a secret URI into its phrase, derivation path and password. An
+// empty phrase selects DevPhrase.
+func Parse(suri string) (*SURI, error) {
+	matches := suriRe.FindStringSubmatchIndex(suri)
+	if matches == nil {
+		return nil, ErrInvalidSURI
+	}
+	group := func(i int) string {
+		if matches[2*i] < 0 {
+			return ""
+		}
+		return suri[matches[2*i]:matches[2*i+1]]
+	}
+
+	parsed := &SURI{
+		Phrase: group(1),
+	}
+	if parsed.Phrase == "" {
+		parsed.Phrase = DevPhrase
+	}
+	// NOTE: "///" with nothing after it is an empty password, not a missing one
+	if matches[6] >= 0 {
+		password := group(3)
+		parsed.Password = &password
+	}
+
+	for _, junction := range junctionRe.FindAllStringSubmatch(group(2), -1) {
+		parsed.Path = append(parsed.Path, NewJunction(junction[2], junction[1] == "/"))
+	}
+
+	return parsed, nil
+}
+
+// NewJunction returns the junction of a path element. Numbers are encoded as
+// u64, anything else as a SCALE string, and the encoding is the chain code,
+// hashed with blake2-256 when longer than 32 bytes.
+func NewJunction(code string, isHard bool) Junction {
+	var encoded []byte
+	if n, err := strconv.ParseUint(code, 10, 64); err == nil {
+		encoded = make([]byte, 8)
+		for i := range encoded {
+			encoded[i] = byte(n >> (8 * uint(i)))
+		}
+	} else {
+		encoded = u8util.Concat(
+			u8compact.CompactToUint8Slice(big.NewInt(int64(len(code))), u8compact.DefaultBitLength),
+			[]byte(code),
+		)
+	}
+
+	junction := Junction{
+		IsHard: isHard,
+	}
+	if len(encoded) > junctionIDLength {
+		junction.ChainCode = *crypto.NewBlake2b256(encoded)
+	} else {
+		copy(junction.ChainCode[:], encoded)
+	}
+
+	return junction
+}
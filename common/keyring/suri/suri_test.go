@@ -0,0 +1,80 @@
+package suri
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/u8util"
+)
+
+func TestParse(t *testing.T) {
+	password := "password"
+	empty := ""
+	phrase := "bottom drive obey lake curtain smoke basket hold race lonely fit walk"
+
+	for i, tt := range []struct {
+		in       string
+		phrase   string
+		path     []Junction
+		password *string
+	}{
+		{"//Alice", DevPhrase, []Junction{NewJunction("Alice", true)}, nil},
+		{phrase, phrase, nil, nil},
+		{phrase + "//hard/soft", phrase, []Junction{NewJunction("hard", true), NewJunction("soft", false)}, nil},
+		{phrase + "///password", phrase, nil, &password},
+		{phrase + "//1///", phrase, []Junction{NewJunction("1", true)}, &empty},
+		{DevSeed + "/soft//hard///password", DevSeed, []Junction{NewJunction("soft", false), NewJunction("hard", true)}, &password},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			parsed, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if parsed.Phrase != tt.phrase {
+				t.Errorf("expected phrase %q, received %q", tt.phrase, parsed.Phrase)
+			}
+			if !reflect.DeepEqual(parsed.Path, tt.path) {
+				t.Errorf("expected path %v, received %v", tt.path, parsed.Path)
+			}
+			if !reflect.DeepEqual(parsed.Password, tt.password) {
+				t.Errorf("expected password %v, received %v", tt.password, parsed.Password)
+			}
+		})
+	}
+
+	t.Run("rejects an invalid uri", func(t *testing.T) {
+		if _, err := Parse("phrase!//Alice"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestNewJunction(t *testing.T) {
+	for i, tt := range []struct {
+		in  string
+		out string
+	}{
+		// NOTE: a SCALE string, compact length then bytes
+		{"Alice", "0x14416c696365" + "0000000000000000000000000000000000000000000000000000"},
+		// NOTE: a u64, little endian
+		{"1", "0x0100000000000000000000000000000000000000000000000000000000000000"},
+		// NOTE: longer than 32 bytes, hashed
+		{"a very long junction name that needs hashing", ""},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			junction := NewJunction(tt.in, true)
+			if tt.out == "" {
+				if junction.ChainCode == ([32]byte{}) {
+					t.Error("expected a chain code")
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(junction.ChainCode[:], u8util.FromHex(tt.out)) {
+				t.Errorf("expected %v, received %x", tt.out, junction.ChainCode)
+			}
+		})
+	}
+}
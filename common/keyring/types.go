@@ -1,6 +1,13 @@
 package keyring
 
-import "github.com/tsfdsong/go-polkadot/common/keyring/pair"
+import (
+	"sync"
+	"time"
+
+	"github.com/tsfdsong/go-polkadot/common/keyring/address"
+	"github.com/tsfdsong/go-polkadot/common/keyring/keystore"
+	"github.com/tsfdsong/go-polkadot/common/keyring/pair"
+)
 
 var (
 	// note: ensure the struct(s) implement the interface(s) at compile time
@@ -10,4 +17,29 @@ var (
 // KeyRing ...
 type KeyRing struct {
 	Pairs pair.InterfacePairs
+	// LegacyMnemonic derives the seed of a mnemonic from its BIP39 seed, as
+	// earlier releases did, instead of from its entropy as Substrate does
+	LegacyMnemonic bool
+	prefix         address.PrefixEnum
+	store          keystore.InterfaceKeyStore
+	password       string
+	lockTimeout    time.Duration
+	lockTimers     map[string]*time.Timer
+	// note: the timers have their own lock, AddPair persists while holding mu
+	timersMu sync.Mutex
+	mu       sync.RWMutex
+}
+
+// Options ...
+type Options struct {
+	// Prefix encodes the addresses, nil selects address.DefaultPrefix
+	Prefix address.PrefixEnum
+	// Store persists the pairs, which are loaded locked from it. nil keeps
+	// the pairs in memory only.
+	Store keystore.InterfaceKeyStore
+	// Password encrypts the pairs written to Store
+	Password string
+	// LockTimeout locks the pairs of Store this long after they were
+	// unlocked, 0 keeps them unlocked
+	LockTimeout time.Duration
 }
@@ -0,0 +1,265 @@
+package leveldb
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/dirutil"
+)
+
+var defaultFile = "store.ldb"
+
+// LevelDB is a db.BaseDB on an embedded goleveldb store
+type LevelDB struct {
+	db      *leveldb.DB
+	path    string
+	options *opt.Options
+	write   *opt.WriteOptions
+}
+
+// NewLevelDB creates a LevelDB database stored in the directory base/file
+func NewLevelDB(base, file string, options *db.BaseDBOptions) *LevelDB {
+	if file == "" {
+		file = defaultFile
+	}
+
+	ldbOptions := &opt.Options{
+		Compression: opt.NoCompression,
+	}
+	write := &opt.WriteOptions{}
+
+	if options != nil {
+		switch {
+		case options.Compression == db.CompressionZstd:
+			log.Println("[leveldb] zstd is not supported, using snappy")
+			ldbOptions.Compression = opt.SnappyCompression
+		case options.Compression == db.CompressionSnappy || options.IsCompressed:
+			ldbOptions.Compression = opt.SnappyCompression
+		}
+
+		ldbOptions.ReadOnly = options.ReadOnly
+		ldbOptions.ErrorIfMissing = options.ErrorIfMissing
+
+		// NOTE: the write-ahead log is always written, this only controls the fsync
+		write.Sync = options.Sync == db.SyncEveryCommit
+	}
+
+	return &LevelDB{
+		path:    dirutil.NormalizePath(fmt.Sprintf("%s/%s", base, file)),
+		options: ldbOptions,
+		write:   write,
+	}
+}
+
+// NewTransactionDB returns a LevelDB wrapped in a db.TransactionDB, usable as
+// the db.TXDB of a triedb.TrieDB
+func NewTransactionDB(base, file string, options *db.BaseDBOptions) *db.TransactionDB {
+	basedb := db.BaseDB(NewLevelDB(base, file, options))
+	return db.NewTransactionDB(&basedb)
+}
+
+// AssertOpen ...
+func (l *LevelDB) AssertOpen(open bool) {
+	if open && l.db == nil {
+		log.Fatal("expected an open database")
+	} else if !open && l.db != nil {
+		log.Fatal("expected a closed database")
+	}
+}
+
+// Open ...
+func (l *LevelDB) Open() {
+	l.AssertOpen(false)
+
+	ldb, err := leveldb.OpenFile(l.path, l.options)
+	if err != nil {
+		log.Fatalf("[leveldb] failed to open %s: %v", l.path, err)
+	}
+
+	l.db = ldb
+}
+
+// Close ...
+func (l *LevelDB) Close() {
+	l.AssertOpen(true)
+
+	if err := l.db.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	l.db = nil
+}
+
+// Drop ...
+func (l *LevelDB) Drop() {
+	l.AssertOpen(false)
+
+	if err := os.RemoveAll(l.path); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Empty removes all entries, reopening the database when it is open
+func (l *LevelDB) Empty() {
+	isOpen := l.db != nil
+	if isOpen {
+		l.Close()
+	}
+
+	l.Drop()
+
+	if isOpen {
+		l.Open()
+	}
+}
+
+// Rename ...
+func (l *LevelDB) Rename(base, file string) {
+	l.AssertOpen(false)
+
+	path := dirutil.NormalizePath(fmt.Sprintf("%s/%s", base, file))
+	if err := os.Rename(l.path, path); err != nil {
+		log.Fatal(err)
+	}
+
+	l.path = path
+}
+
+// Maintain runs a manual compaction of the whole key range. A closed database
+// is opened for the duration of the compaction.
+func (l *LevelDB) Maintain(fn *db.ProgressCB) error {
+	if l.db == nil {
+		l.Open()
+		defer l.Close()
+	}
+
+	if err := l.db.CompactRange(util.Range{}); err != nil {
+		return err
+	}
+
+	if fn != nil {
+		f := *fn
+		f(&db.ProgressValue{
+			IsCompleted: true,
+			Keys:        0,
+			Percent:     100,
+		})
+	}
+
+	return nil
+}
+
+// Size returns the size of the database files on disk
+func (l *LevelDB) Size() int {
+	var size int64
+	err := filepath.Walk(l.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+
+	return int(size)
+}
+
+// Del ...
+func (l *LevelDB) Del(key []uint8) {
+	l.AssertOpen(true)
+
+	if err := l.db.Delete(key, l.write); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Get ...
+func (l *LevelDB) Get(key []uint8) []uint8 {
+	l.AssertOpen(true)
+
+	value, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	return value
+}
+
+// Put ...
+func (l *LevelDB) Put(key, value []uint8) {
+	l.AssertOpen(true)
+
+	if err := l.db.Put(key, value, l.write); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Iterate ...
+func (l *LevelDB) Iterate(prefix []uint8, fn func(key, value []uint8) bool) {
+	l.AssertOpen(true)
+
+	iter := l.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		// NOTE: the iterator reuses its buffers
+		key := append([]uint8(nil), iter.Key()...)
+		value := append([]uint8(nil), iter.Value()...)
+		if !fn(key, value) {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewBatch ...
+func (l *LevelDB) NewBatch() db.Batch {
+	return &Batch{
+		db:    l,
+		batch: new(leveldb.Batch),
+	}
+}
+
+// Batch is written atomically through the leveldb write-ahead log
+type Batch struct {
+	db    *LevelDB
+	batch *leveldb.Batch
+}
+
+// Put ...
+func (b *Batch) Put(key, value []uint8) {
+	b.batch.Put(key, value)
+}
+
+// Del ...
+func (b *Batch) Del(key []uint8) {
+	b.batch.Delete(key)
+}
+
+// Write ...
+func (b *Batch) Write() error {
+	b.db.AssertOpen(true)
+
+	if err := b.db.db.Write(b.batch, b.db.write); err != nil {
+		return err
+	}
+
+	b.batch.Reset()
+	return nil
+}
@@ -0,0 +1,107 @@
+package leveldb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/triedb"
+)
+
+func TestLevelDB(t *testing.T) {
+	location := t.TempDir()
+	store := NewLevelDB(location, "", &db.BaseDBOptions{IsCompressed: true})
+	store.Open()
+
+	t.Run("writes, reads and deletes an entry", func(t *testing.T) {
+		store.Put([]uint8("key"), []uint8("value"))
+		if !reflect.DeepEqual(store.Get([]uint8("key")), []uint8("value")) {
+			t.Fail()
+		}
+
+		store.Del([]uint8("key"))
+		if store.Get([]uint8("key")) != nil {
+			t.Fail()
+		}
+	})
+
+	t.Run("iterates a prefix in order", func(t *testing.T) {
+		for _, key := range []string{"b2", "a1", "b1"} {
+			store.Put([]uint8(key), []uint8(key))
+		}
+
+		var keys []string
+		store.Iterate([]uint8("b"), func(key, value []uint8) bool {
+			keys = append(keys, string(key))
+			return true
+		})
+
+		if !reflect.DeepEqual(keys, []string{"b1", "b2"}) {
+			t.Errorf("unexpected keys %v", keys)
+		}
+	})
+
+	t.Run("writes a batch", func(t *testing.T) {
+		batch := store.NewBatch()
+		batch.Put([]uint8("c1"), []uint8("c1"))
+		batch.Del([]uint8("a1"))
+
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if store.Get([]uint8("a1")) != nil || store.Get([]uint8("c1")) == nil {
+			t.Fail()
+		}
+	})
+
+	t.Run("compacts and reports the on-disk size", func(t *testing.T) {
+		if err := store.Maintain(nil); err != nil {
+			t.Fatal(err)
+		}
+		if store.Size() == 0 {
+			t.Error("expected a non-zero size")
+		}
+	})
+
+	t.Run("keeps the entries across a reopen", func(t *testing.T) {
+		store.Close()
+		store.Open()
+
+		if !reflect.DeepEqual(store.Get([]uint8("c1")), []uint8("c1")) {
+			t.Fail()
+		}
+	})
+
+	t.Run("empties the database", func(t *testing.T) {
+		store.Empty()
+		if store.Get([]uint8("c1")) != nil {
+			t.Fail()
+		}
+	})
+
+	store.Close()
+}
+
+func TestLevelDBTrie(t *testing.T) {
+	codec := triedb.NewRLPCodec()
+
+	txdb := NewTransactionDB(t.TempDir(), "", nil)
+	txdb.Open()
+	defer txdb.Close()
+	trie := triedb.NewTrieDB(txdb, nil, codec)
+
+	memdb := db.BaseDB(db.NewMemoryDB(&db.BaseOptions{}))
+	expected := triedb.NewTrieDB(db.NewTransactionDB(&memdb), nil, codec)
+
+	for _, kv := range [][2]string{{"do", "verb"}, {"doge", "coin"}, {"done", "finished"}} {
+		trie.Put([]uint8(kv[0]), []uint8(kv[1]))
+		expected.Put([]uint8(kv[0]), []uint8(kv[1]))
+	}
+
+	if !reflect.DeepEqual(trie.GetRoot(), expected.GetRoot()) {
+		t.Errorf("expected root %x, got %x", expected.GetRoot(), trie.GetRoot())
+	}
+	if !reflect.DeepEqual(trie.Get([]uint8("doge")), []uint8("coin")) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms
+var DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Counter is a monotonically increasing value. A nil Counter is a noop, so
+// hooks don't need to check whether metrics are enabled.
+type Counter struct {
+	value int64
+}
+
+// Inc ...
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add ...
+func (c *Counter) Add(n int64) {
+	if c == nil {
+		return
+	}
+
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value ...
+func (c *Counter) Value() int64 {
+	if c == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&c.value)
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram, Counts holding the
+// cumulative number of observations less than or equal to each bucket
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+// Histogram counts observations into buckets. A nil Histogram is a noop.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds
+func NewHistogram(buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)),
+	}
+}
+
+// Observe ...
+func (h *Histogram) Observe(value float64) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	index := sort.SearchFloat64s(h.buckets, value)
+	if index < len(h.counts) {
+		h.counts[index]++
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// ObserveSince observes the seconds elapsed since start
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Snapshot ... A nil Histogram returns an empty snapshot.
+func (h *Histogram) Snapshot() *HistogramSnapshot {
+	if h == nil {
+		return &HistogramSnapshot{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := &HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  make([]uint64, len(h.counts)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		snapshot.Counts[i] = cumulative
+	}
+
+	return snapshot
+}
+
+// Collector receives the metrics of a Registry, bridges to monitoring systems
+// such as Prometheus implement it
+type Collector interface {
+	CollectCounter(name string, value int64)
+	CollectHistogram(name string, snapshot *HistogramSnapshot)
+}
+
+// Registry holds named counters and histograms. A nil Registry hands out nil
+// metrics, disabling them.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]*Counter
+	histograms map[string]*Histogram
+}
+
+// NewRegistry ...
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   map[string]*Counter{},
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// Counter returns the counter registered under name, creating it when missing
+func (r *Registry) Counter(name string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter, found := r.counters[name]
+	if !found {
+		counter = &Counter{}
+		r.counters[name] = counter
+	}
+
+	return counter
+}
+
+// Histogram returns the latency histogram registered under name, creating it
+// with DefaultBuckets when missing
+func (r *Registry) Histogram(name string) *Histogram {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	histogram, found := r.histograms[name]
+	if !found {
+		histogram = NewHistogram(DefaultBuckets)
+		r.histograms[name] = histogram
+	}
+
+	return histogram
+}
+
+// Collect passes every metric to the collector, in name order
+func (r *Registry) Collect(collector Collector) {
+	r.mu.Lock()
+	var counterNames, histogramNames []string
+	for name := range r.counters {
+		counterNames = append(counterNames, name)
+	}
+	for name := range r.histograms {
+		histogramNames = append(histogramNames, name)
+	}
+	r.mu.Unlock()
+
+	sort.Strings(counterNames)
+	sort.Strings(histogramNames)
+
+	for _, name := range counterNames {
+		collector.CollectCounter(name, r.Counter(name).Value())
+	}
+
+	for _, name := range histogramNames {
+		collector.CollectHistogram(name, r.Histogram(name).Snapshot())
+	}
+}
+
+// WriteText dumps the metrics in a line based text format, one line per
+// counter and per histogram bucket
+func (r *Registry) WriteText(w io.Writer) error {
+	writer := &textWriter{w: w}
+	r.Collect(writer)
+
+	return writer.err
+}
+
+// textWriter ...
+type textWriter struct {
+	w   io.Writer
+	err error
+}
+
+// CollectCounter ...
+func (t *textWriter) CollectCounter(name string, value int64) {
+	t.printf("%s %d\n", name, value)
+}
+
+// CollectHistogram ...
+func (t *textWriter) CollectHistogram(name string, snapshot *HistogramSnapshot) {
+	for i, bucket := range snapshot.Buckets {
+		t.printf("%s_bucket{le=\"%g\"} %d\n", name, bucket, snapshot.Counts[i])
+	}
+
+	t.printf("%s_bucket{le=\"%g\"} %d\n", name, math.Inf(1), snapshot.Count)
+	t.printf("%s_sum %g\n", name, snapshot.Sum)
+	t.printf("%s_count %d\n", name, snapshot.Count)
+}
+
+// printf writes unless a previous write failed
+func (t *textWriter) printf(format string, args ...interface{}) {
+	if t.err != nil {
+		return
+	}
+
+	_, t.err = fmt.Fprintf(t.w, format, args...)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+
+	t.Run("returns the same counter for a name", func(t *testing.T) {
+		registry.Counter("puts").Inc()
+		registry.Counter("puts").Add(2)
+
+		if registry.Counter("puts").Value() != 3 {
+			t.Errorf("expected 3, got %d", registry.Counter("puts").Value())
+		}
+	})
+
+	t.Run("counts observations into cumulative buckets", func(t *testing.T) {
+		histogram := NewHistogram([]float64{1, 2})
+		histogram.Observe(0.5)
+		histogram.Observe(1.5)
+		histogram.Observe(3)
+
+		snapshot := histogram.Snapshot()
+		if !reflect.DeepEqual(snapshot.Counts, []uint64{1, 2}) {
+			t.Errorf("unexpected counts %v", snapshot.Counts)
+		}
+		if snapshot.Count != 3 || snapshot.Sum != 5 {
+			t.Errorf("unexpected count %d and sum %g", snapshot.Count, snapshot.Sum)
+		}
+	})
+
+	t.Run("ignores a disabled registry", func(t *testing.T) {
+		var disabled *Registry
+		disabled.Counter("puts").Inc()
+		disabled.Histogram("put_seconds").Observe(1)
+
+		if disabled.Counter("puts").Value() != 0 {
+			t.Fail()
+		}
+		if disabled.Histogram("put_seconds").Snapshot().Count != 0 {
+			t.Fail()
+		}
+	})
+
+	t.Run("writes a text dump", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Counter("b_total").Add(2)
+		registry.Counter("a_total").Inc()
+		registry.Histogram("latency").Observe(0.00002)
+
+		var buf bytes.Buffer
+		if err := registry.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if string(lines[0]) != "a_total 1" || string(lines[1]) != "b_total 2" {
+			t.Errorf("unexpected counters %q", lines[:2])
+		}
+		if string(lines[2]) != `latency_bucket{le="1e-05"} 0` || string(lines[3]) != `latency_bucket{le="5e-05"} 1` {
+			t.Errorf("unexpected buckets %q", lines[2:4])
+		}
+		if string(lines[len(lines)-1]) != "latency_count 1" {
+			t.Errorf("unexpected count %q", lines[len(lines)-1])
+		}
+	})
+}
@@ -3,4 +3,6 @@ package mnemonic
 const (
 	// DefaultEntropy ...
 	DefaultEntropy int = 128
+	// pbkdf2Rounds is the iteration count of the Substrate mini secret
+	pbkdf2Rounds int = 2048
 )
@@ -1,9 +1,11 @@
 package mnemonic
 
 import (
+	"crypto/sha512"
 	"errors"
 
 	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
 )
 
 // Generate ...
@@ -27,14 +29,36 @@ func Validate(mnemonic string) bool {
 	return bip39.IsMnemonicValid(mnemonic)
 }
 
-// ToSecret ...
+// ToSecret returns the 64 byte BIP39 seed of a mnemonic
 func ToSecret(mnemonic, password string) ([]byte, error) {
-	// TODO: is this correct? rather 'bip32.NewMasterKey(seed)'?
 	return bip39.NewSeed(mnemonic, password), nil
 }
 
-// ToSeed ...
+// ToMiniSecret returns the 64 byte secret Substrate derives from a mnemonic,
+// the PBKDF2 of its entropy rather than of its words as BIP39 does
+func ToMiniSecret(mnemonic, password string) ([]byte, error) {
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	return pbkdf2.Key(entropy, []byte("mnemonic"+password), pbkdf2Rounds, 64, sha512.New), nil
+}
+
+// ToSeed returns the 32 byte seed of a mnemonic, as Substrate, subkey and
+// polkadot.js derive it
 func ToSeed(mnemonic, password string) ([]byte, error) {
+	tmp, err := ToMiniSecret(mnemonic, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp[:32], nil
+}
+
+// ToLegacySeed returns the first 32 bytes of the BIP39 seed of a mnemonic, the
+// seed earlier releases derived. Pairs created that way need it to be restored.
+func ToLegacySeed(mnemonic, password string) ([]byte, error) {
 	tmp, err := ToSecret(mnemonic, password)
 	if err != nil {
 		return nil, err
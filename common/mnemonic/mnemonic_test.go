@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/tsfdsong/go-polkadot/common/u8util"
 )
 
 func TestGenerate(t *testing.T) {
@@ -52,7 +54,7 @@ func TestToSecret(t *testing.T) {
 	}
 }
 
-func TestToSeed(t *testing.T) {
+func TestToLegacySeed(t *testing.T) {
 	for i, tt := range []struct {
 		mn       string
 		password string
@@ -70,7 +72,7 @@ func TestToSeed(t *testing.T) {
 		},
 	} {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
-			seed, err := ToSeed(tt.mn, tt.password)
+			seed, err := ToLegacySeed(tt.mn, tt.password)
 			if err != nil {
 				t.Error(err)
 			}
@@ -82,6 +84,43 @@ func TestToSeed(t *testing.T) {
 	}
 }
 
+func TestToSeed(t *testing.T) {
+	for i, tt := range []struct {
+		mn       string
+		password string
+		out      string
+	}{
+		{
+			// NOTE: the development phrase of Substrate and its well-known seed
+			"bottom drive obey lake curtain smoke basket hold race lonely fit walk",
+			"",
+			"0xfac7959dbfe72f052e5a0c3c8d6530f202b02fd8f9f5ca3580ec8deb7797479e",
+		},
+		{
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"Substrate",
+			"0x44e9d125f037ac1d51f0a7d3649689d422c2af8b1ec8e00d71db4d7bf6d127e3",
+		},
+	} {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			seed, err := ToSeed(tt.mn, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(seed, u8util.FromHex(tt.out)) {
+				t.Errorf("expected %v, received %x", tt.out, seed)
+			}
+		})
+	}
+
+	t.Run("rejects an invalid mnemonic", func(t *testing.T) {
+		if _, err := ToSeed("basket actual", ""); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
 func TestValidate(t *testing.T) {
 	for i, tt := range []struct {
 		in  string
@@ -7,6 +7,7 @@ import (
 	"reflect"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 	"github.com/tsfdsong/go-polkadot/common/triecodec"
 	"github.com/tsfdsong/go-polkadot/common/u8util"
 )
@@ -15,10 +16,17 @@ import (
 
 // Impl ...
 type Impl struct {
-	checkpoint *Checkpoint
-	db         db.TXDB
-	codec      InterfaceCodec
-	Debug      bool
+	checkpoint   *Checkpoint
+	db           db.TXDB
+	codec        InterfaceCodec
+	Debug        bool
+	nodeReads    *metrics.Counter
+	nodeWrites   *metrics.Counter
+	bytesRead    *metrics.Counter
+	bytesWritten *metrics.Counter
+	getLatency   *metrics.Histogram
+	putLatency   *metrics.Histogram
+	delLatency   *metrics.Histogram
 }
 
 // TxDB ...
@@ -42,6 +50,18 @@ func (i *Impl) SetDebug(enabled bool) {
 	i.Debug = enabled
 }
 
+// SetMetrics registers the node reads and writes and the latency of the trie
+// operations as <name>_<metric>
+func (i *Impl) SetMetrics(registry *metrics.Registry, name string) {
+	i.nodeReads = registry.Counter(name + "_node_reads")
+	i.nodeWrites = registry.Counter(name + "_node_writes")
+	i.bytesRead = registry.Counter(name + "_bytes_read")
+	i.bytesWritten = registry.Counter(name + "_bytes_written")
+	i.getLatency = registry.Histogram(name + "_get_seconds")
+	i.putLatency = registry.Histogram(name + "_put_seconds")
+	i.delLatency = registry.Histogram(name + "_del_seconds")
+}
+
 // DebugLog ...
 func (i *Impl) DebugLog(ifcs ...interface{}) {
 	if i.Debug {
@@ -50,47 +70,91 @@ func (i *Impl) DebugLog(ifcs ...interface{}) {
 	}
 }
 
-// Snapshot ...
-func (i *Impl) Snapshot(dest *TrieDB, fn db.ProgressCB, root []byte, keys int, percent int, depth int) int {
+// snapshotState tracks the progress of a snapshot
+type snapshotState struct {
+	dest  *TrieDB
+	fn    db.ProgressCB
+	keys  int
+	bytes int
+	// NOTE: the share of the trie done, a node sharing its weight equally
+	// between its children
+	done float64
+}
+
+// report ...
+func (s *snapshotState) report() {
+	if s.fn == nil {
+		return
+	}
+
+	s.fn(&db.ProgressValue{
+		IsCompleted: false,
+		Keys:        s.keys,
+		Bytes:       s.bytes,
+		Percent:     math.Min(s.done*100, 100),
+	})
+}
+
+// Snapshot copies the node at root and its descendants to the destination.
+// Children are written before their parent, so a node found in the
+// destination heads a complete subtree and is skipped, and an interrupted
+// snapshot leaves only complete subtrees behind.
+func (i *Impl) Snapshot(state *snapshotState, root []byte, weight float64) error {
 	i.DebugLog("Snapshot, root", root)
+	if state.dest.impl.db.Get(root) != nil {
+		i.DebugLog("Snapshot, node exists", root)
+		state.done += weight
+		state.report()
+		return nil
+	}
+
 	node := i.GetNode(root)
 	i.DebugLog("Snapshot, GetNode result", node)
 
 	if IsNull(node) {
 		i.DebugLog("Snapshot, node is null", node)
-		return keys
-	}
-
-	keys++
-
-	i.DebugLog("Snapshot, call Put with root", root)
-	encodedNode := EncodeNode(node, i.codec)
-	i.DebugLog("Snapshot, call Put with encoded node", encodedNode)
-	dest.impl.db.Put(root[:], encodedNode)
-
-	if fn != nil {
-		fn(&db.ProgressValue{
-			IsCompleted: false,
-			Keys:        keys,
-			Percent:     percent,
-		})
+		state.done += weight
+		return nil
 	}
 
 	nodes := NewNodeListFromNode(node)
 	if len(nodes) == 0 {
-		log.Fatal("Snapshot: not ok")
+		return fmt.Errorf("Snapshot: unexpected node %x", root)
 	}
 
+	share := weight / float64(len(nodes))
 	for _, val := range nodes {
 		v := NewUint8FromNode(val)
 		if v != nil && len(v) == 32 {
-			keys = i.Snapshot(dest, fn, v, keys, percent, depth+1)
+			if err := i.Snapshot(state, v, share); err != nil {
+				return err
+			}
+		} else {
+			state.done += share
 		}
+	}
+
+	encodedNode := EncodeNode(node, i.codec)
+	i.DebugLog("Snapshot, call Put with encoded node", encodedNode)
+	if err := putChecked(state.dest.impl.db, root, encodedNode); err != nil {
+		return err
+	}
+
+	state.keys++
+	state.bytes += len(encodedNode)
+	state.report()
+
+	return nil
+}
 
-		percent += int((float64(100) / float64(len(nodes))) / math.Pow(float64(16), float64(depth)))
+// putChecked writes through db.Checked when the database implements it
+func putChecked(txdb db.TXDB, key, value []byte) error {
+	if checked, ok := txdb.(db.Checked); ok {
+		return checked.TryPut(key, value)
 	}
 
-	return keys
+	txdb.Put(key, value)
+	return nil
 }
 
 // GetNode ...
@@ -115,6 +179,8 @@ func (i *Impl) GetNode(hash interface{}) Node {
 
 	i.DebugLog("GetNode, get hash", hash)
 	x := i.db.Get(hash.([]uint8))
+	i.nodeReads.Inc()
+	i.bytesRead.Add(int64(len(x)))
 	i.DebugLog("GetNode, get hash result", x)
 	y := DecodeNode(x, i.codec)
 	i.DebugLog("GetNode, decode node result", y)
@@ -457,6 +523,8 @@ func (i *Impl) PersistNode(node Node) Node {
 	if value != nil {
 		k := NewUint8FromNode(ikey)
 		i.db.Put(k, value)
+		i.nodeWrites.Inc()
+		i.bytesWritten.Add(int64(len(value)))
 	}
 
 	key := NewNode(ikey)
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/tsfdsong/go-polkadot/common/db"
 	"github.com/tsfdsong/go-polkadot/common/triehash"
 )
 
@@ -87,4 +88,110 @@ func TestSnapshots(t *testing.T) {
 			}
 		}
 	})
+	t.Run("forks the trie without copying", func(t *testing.T) {
+		trie := newTrie(codec)
+		trie.Put([]uint8("do"), []uint8("verb"))
+		root := trie.GetRoot()
+
+		fork, err := trie.Fork()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork.Put([]uint8("doge"), []uint8("coin"))
+
+		if !reflect.DeepEqual(trie.GetRoot(), root) {
+			t.Errorf("expected the original root to be unchanged")
+		}
+		if trie.Get([]uint8("doge")) != nil {
+			t.Errorf("expected the fork writes to be isolated")
+		}
+		if !reflect.DeepEqual(fork.Get([]uint8("do")), []uint8("verb")) {
+			t.Errorf("expected the fork to share the original data")
+		}
+
+		trie.Put([]uint8("done"), []uint8("finished"))
+		if fork.Get([]uint8("done")) != nil {
+			t.Errorf("expected the original writes to be isolated")
+		}
+	})
+	t.Run("skips the nodes the destination holds", func(t *testing.T) {
+		trie := newTrie(codec)
+		back := newTrie(codec)
+		trie.Put([]uint8("do"), []uint8("verb"))
+		trie.Put([]uint8("doge"), []uint8("coin"))
+		trie.Put([]uint8("done"), []uint8("finished"))
+
+		keys, err := trie.Snapshot(back, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys == 0 {
+			t.Fatalf("expected nodes to be written")
+		}
+
+		again, err := trie.Snapshot(back, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != 0 {
+			t.Errorf("expected no nodes to be written, got %d", again)
+		}
+	})
+
+	t.Run("resumes an interrupted snapshot", func(t *testing.T) {
+		trie := newTrie(codec)
+		back := newTrie(codec)
+		trie.Put([]uint8("do"), []uint8("verb"))
+		trie.Put([]uint8("doge"), []uint8("coin"))
+
+		if _, err := trie.Snapshot(back, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// NOTE: the children are written first, an interruption misses the root
+		root := trie.GetRoot()
+		back.impl.db.Del(root)
+
+		keys, err := trie.Snapshot(back, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys != 1 {
+			t.Errorf("expected only the root to be written, got %d", keys)
+		}
+		if !reflect.DeepEqual(back.Get([]uint8("doge")), []uint8("coin")) {
+			t.Errorf("expected the value in the destination")
+		}
+	})
+
+	t.Run("reports the progress", func(t *testing.T) {
+		trie := newTrie(codec)
+		back := newTrie(codec)
+		trie.Put([]uint8("do"), []uint8("verb"))
+		trie.Put([]uint8("doge"), []uint8("coin"))
+		trie.Put([]uint8("done"), []uint8("finished"))
+
+		var progress []*db.ProgressValue
+		keys, err := trie.Snapshot(back, func(value *db.ProgressValue) {
+			progress = append(progress, value)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last := progress[len(progress)-1]
+		if !last.IsCompleted || last.Keys != keys || last.Percent != 100 || last.Bytes == 0 {
+			t.Errorf("unexpected completion %+v", last)
+		}
+
+		previous := progress[len(progress)-2]
+		if previous.Percent < 99.9 || previous.Bytes != last.Bytes {
+			t.Errorf("unexpected progress %+v", previous)
+		}
+		for i := 1; i < len(progress); i++ {
+			if progress[i].Percent < progress[i-1].Percent {
+				t.Errorf("expected the percent to increase")
+			}
+		}
+	})
 }
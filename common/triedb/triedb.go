@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 	"github.com/tsfdsong/go-polkadot/common/triecodec"
 	"github.com/tsfdsong/go-polkadot/common/triehash"
 )
@@ -31,6 +32,11 @@ func (t *TrieDB) SetDebug(enabled bool) {
 	t.impl.Debug = enabled
 }
 
+// SetMetrics ...
+func (t *TrieDB) SetMetrics(registry *metrics.Registry, name string) {
+	t.impl.SetMetrics(registry, name)
+}
+
 // DebugLog ...
 func (t *TrieDB) DebugLog(i ...interface{}) {
 	if t.Debug {
@@ -96,6 +102,8 @@ func (t *TrieDB) Size() int {
 
 // Del ...
 func (t *TrieDB) Del(key []uint8) {
+	defer t.impl.delLatency.ObserveSince(time.Now())
+
 	t.DebugLog("Del, root hash", t.impl.checkpoint.rootHash)
 	n := t.impl.GetNode(t.impl.checkpoint.rootHash)
 	t.DebugLog("Del, get node", n)
@@ -113,6 +121,8 @@ func (t *TrieDB) Del(key []uint8) {
 
 // Get ...
 func (t *TrieDB) Get(key []uint8) []uint8 {
+	defer t.impl.getLatency.ObserveSince(time.Now())
+
 	t.DebugLog("Get, key str", string(key))
 	t.DebugLog("Get, root hash", t.impl.checkpoint.rootHash)
 	x := t.impl.GetNode(t.impl.checkpoint.rootHash)
@@ -134,6 +144,8 @@ func (t *TrieDB) Get(key []uint8) []uint8 {
 
 // Put ...
 func (t *TrieDB) Put(key, value []uint8) {
+	defer t.impl.putLatency.ObserveSince(time.Now())
+
 	t.DebugLog("Put, key str", string(key))
 	t.DebugLog("Put, value", string(value))
 	n := t.impl.GetNode(t.impl.checkpoint.rootHash)
@@ -182,31 +194,71 @@ func (t *TrieDB) SetRoot(rootHash []byte) {
 	t.impl.checkpoint.rootHash = rootHash
 }
 
-// Snapshot ...
-func (t *TrieDB) Snapshot(dest *TrieDB, fn db.ProgressCB) int {
-	start := time.Now().Unix()
+// Fork returns a trie at the current root on a copy-on-write fork of the
+// database, db.ErrNotForkable when the database doesn't support it. Unlike
+// Snapshot no node is copied, the writes to either trie are not seen by the
+// other.
+func (t *TrieDB) Fork() (*TrieDB, error) {
+	forkable, ok := t.impl.db.(db.Forkable)
+	if !ok {
+		return nil, db.ErrNotForkable
+	}
 
-	keys := t.impl.Snapshot(dest, fn, t.impl.checkpoint.rootHash, 0, 0, 0)
-	elapsed := time.Now().Unix() - start
+	forked, err := forkable.Fork()
+	if err != nil {
+		return nil, err
+	}
 
-	dest.SetRoot(t.impl.checkpoint.rootHash)
+	txdb, ok := forked.(db.TXDB)
+	if !ok {
+		return nil, fmt.Errorf("fork of %T is not a db.TXDB", t.impl.db)
+	}
+
+	rootHash := append([]byte(nil), t.impl.checkpoint.rootHash...)
+	fork := NewTrieDB(txdb, rootHash, t.impl.codec)
+	fork.SetDebug(t.Debug)
+
+	return fork, nil
+}
+
+// Snapshot copies the nodes of the current root to dest, returning the number
+// of nodes written. Nodes dest already holds are skipped, so the copy is
+// idempotent: a snapshot interrupted part way is completed by calling Snapshot
+// again, which walks the trie anew and writes only the missing nodes.
+func (t *TrieDB) Snapshot(dest *TrieDB, fn db.ProgressCB) (int, error) {
+	start := time.Now()
+	root := t.impl.checkpoint.rootHash
+
+	state := &snapshotState{
+		dest: dest,
+		fn:   fn,
+	}
+	if err := t.impl.Snapshot(state, root, 1); err != nil {
+		return state.keys, err
+	}
+
+	dest.SetRoot(root)
 
 	newSize := dest.impl.db.Size()
 	t.DebugLog("Snapshot, new size", newSize)
 	currentSize := t.impl.db.Size()
 	t.DebugLog("Snapshot, current size", currentSize)
-	percentage := 100 * (newSize / currentSize)
+	var percentage float64
+	if currentSize > 0 {
+		percentage = 100 * float64(newSize) / float64(currentSize)
+	}
 	sizeMB := newSize / (1024 * 1024)
 
-	log.Printf("snapshot created in %d, %dk keys, %dMB (%d%%)", elapsed, keys/1e3, sizeMB, percentage)
+	log.Printf("snapshot created in %s, %d keys, %dMB (%.1f%%)", time.Since(start), state.keys, sizeMB, percentage)
 
 	if fn != nil {
 		fn(&db.ProgressValue{
 			IsCompleted: true,
-			Keys:        keys,
+			Keys:        state.keys,
+			Bytes:       state.bytes,
 			Percent:     100,
 		})
 	}
 
-	return keys
+	return state.keys, nil
 }
@@ -3,9 +3,11 @@ package triedb
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tsfdsong/go-polkadot/common/db"
+	"github.com/tsfdsong/go-polkadot/common/metrics"
 )
 
 func newTrie(codec InterfaceCodec) *TrieDB {
@@ -271,3 +273,23 @@ func TestTrieDB(t *testing.T) {
 		})
 	})
 }
+
+func TestTrieDBMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	trie := newTrie(NewRLPCodec())
+	trie.SetMetrics(registry, "trie")
+
+	trie.Put([]uint8("doge"), []uint8("coin"))
+	trie.Put([]uint8("done"), []uint8(strings.Repeat("finished", 8)))
+	trie.Get([]uint8("doge"))
+
+	if registry.Counter("trie_node_writes").Value() == 0 || registry.Counter("trie_node_reads").Value() == 0 {
+		t.Error("expected node reads and writes to be counted")
+	}
+	if registry.Histogram("trie_put_seconds").Snapshot().Count != 2 {
+		t.Error("expected the puts to be timed")
+	}
+	if registry.Histogram("trie_get_seconds").Snapshot().Count != 1 {
+		t.Error("expected the get to be timed")
+	}
+}